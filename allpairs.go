@@ -0,0 +1,196 @@
+package hwy
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Matrix holds the cost of the shortest path between every pair of places in
+// a graph, as well as the information needed to reconstruct the paths.
+type Matrix struct {
+	// Places gives the order of the rows and columns, sorted by state.
+	Places []Place
+
+	index map[Place]int
+	cost  [][]float64 // cost[i][j] is the cost from Places[i] to Places[j]
+	next  [][]int     // next[i][j] is the index of the place after i on the way to j
+}
+
+// newMatrix creates a Matrix for the places in g with every cost set to
+// infinity and no paths.
+func newMatrix(g Graph) *Matrix {
+	places := g.Places()
+	sort.Sort(ByState(places))
+
+	m := &Matrix{
+		Places: places,
+		index:  make(map[Place]int, len(places)),
+		cost:   make([][]float64, len(places)),
+		next:   make([][]int, len(places)),
+	}
+	inf := math.Inf(1)
+	for i, p := range places {
+		m.index[p] = i
+		m.cost[i] = make([]float64, len(places))
+		m.next[i] = make([]int, len(places))
+		for j := range places {
+			m.cost[i][j] = inf
+			m.next[i][j] = -1
+		}
+		m.cost[i][i] = 0
+	}
+	return m
+}
+
+// Cost gives the cost of the shortest path from orig to dest. The cost is
+// positive infinity if there is no path or either place is not in the matrix.
+func (m *Matrix) Cost(orig, dest Place) float64 {
+	i, iok := m.index[orig]
+	j, jok := m.index[dest]
+	if !iok || !jok {
+		return math.Inf(1)
+	}
+	return m.cost[i][j]
+}
+
+// Path gives the shortest path from orig to dest and its cost in the same
+// way as PathMap.Path. If there is no path, or orig and dest are the same,
+// path is nil and sum is 0.
+func (m *Matrix) Path(orig, dest Place) (path []Place, sum float64) {
+	i, iok := m.index[orig]
+	j, jok := m.index[dest]
+	if !iok || !jok || m.next[i][j] < 0 {
+		return nil, 0
+	}
+
+	path = []Place{orig}
+	for k := i; k != j; k = m.next[k][j] {
+		path = append(path, m.Places[m.next[k][j]])
+	}
+	return path, m.cost[i][j]
+}
+
+// WriteCSV writes the cost matrix to w as CSV. The first row and the first
+// column contain the place names (Place.Name()). Pairs without a path have an
+// empty cell.
+func (m *Matrix) WriteCSV(w io.Writer) error {
+	enc := csv.NewWriter(w)
+
+	row := make([]string, len(m.Places)+1)
+	for j, p := range m.Places {
+		row[j+1] = p.Name()
+	}
+	if err := enc.Write(row); err != nil {
+		return err
+	}
+
+	for i, p := range m.Places {
+		row[0] = p.Name()
+		for j, c := range m.cost[i] {
+			if math.IsInf(c, 1) {
+				row[j+1] = ""
+			} else {
+				row[j+1] = strconv.FormatFloat(c, 'f', -1, 64)
+			}
+		}
+		if err := enc.Write(row); err != nil {
+			return err
+		}
+	}
+
+	enc.Flush()
+	return enc.Error()
+}
+
+// AllPairs finds the shortest paths between every pair of places by running
+// Dijkstra's algorithm (ShortestPath) from each place in parallel.
+func (g Graph) AllPairs(by Accessor) *Matrix {
+	m := newMatrix(g)
+
+	sources := make(chan int)
+	wg := sync.WaitGroup{}
+	for n := 0; n < runtime.NumCPU(); n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range sources {
+				m.fillRow(i, g.ShortestPath(m.Places[i], by))
+			}
+		}()
+	}
+
+	for i := range m.Places {
+		sources <- i
+	}
+	close(sources)
+	wg.Wait()
+
+	return m
+}
+
+// fillRow copies the costs from pm into row i of the matrix and works out the
+// first hop of each path from the parents in pm.
+func (m *Matrix) fillRow(i int, pm PathMap) {
+	orig := m.Places[i]
+	for j, dest := range m.Places {
+		d := pm[dest]
+		if d.Hops == 0 {
+			continue // no path (or dest is orig)
+		}
+		m.cost[i][j] = d.Dist
+
+		// walk back along the path until the place after orig is reached
+		n := dest
+		for pm[n].parent != orig {
+			n = pm[n].parent
+		}
+		m.next[i][j] = m.index[n]
+	}
+}
+
+// FloydWarshall finds the shortest paths between every pair of places using
+// the Floyd-Warshall algorithm. The result is the same as AllPairs, but is
+// slower on sparse graphs such as the highway network.
+//
+// https://en.wikipedia.org/wiki/Floyd%E2%80%93Warshall_algorithm
+func (g Graph) FloydWarshall(by Accessor) *Matrix {
+	m := newMatrix(g)
+
+	// start with the direct connections
+	for orig, edges := range g {
+		i := m.index[orig]
+		for dest, w := range edges {
+			j, ok := m.index[dest]
+			if !ok || i == j {
+				continue
+			}
+			if c := by(w); c < m.cost[i][j] {
+				m.cost[i][j] = c
+				m.next[i][j] = j
+			}
+		}
+	}
+
+	// then see if going through k is better for each pair
+	n := len(m.Places)
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			if math.IsInf(m.cost[i][k], 1) {
+				continue
+			}
+			for j := 0; j < n; j++ {
+				if c := m.cost[i][k] + m.cost[k][j]; c < m.cost[i][j] {
+					m.cost[i][j] = c
+					m.next[i][j] = m.next[i][k]
+				}
+			}
+		}
+	}
+
+	return m
+}
@@ -0,0 +1,165 @@
+package hwy
+
+import (
+	"sort"
+)
+
+// These functions find structural weaknesses in the graph: places and
+// connections whose loss would cut the network into pieces. The graph is
+// treated as undirected.
+
+// adjacency gives the neighbors of each place, considering edges in either
+// direction. Neighbors are sorted by ByState.
+func (g Graph) adjacency() map[Place][]Place {
+	adj := make(map[Place][]Place, len(g))
+	for p := range g {
+		adj[p] = []Place{}
+	}
+	for _, e := range g.undirectedEdges() {
+		adj[e.a] = append(adj[e.a], e.b)
+		adj[e.b] = append(adj[e.b], e.a)
+	}
+	for _, n := range adj {
+		sort.Sort(ByState(n))
+	}
+	return adj
+}
+
+// Components finds the connected components of the graph. Each component's
+// places are sorted by ByState, and the components are ordered from largest
+// to smallest.
+func (g Graph) Components() [][]Place {
+	adj := g.adjacency()
+	seen := make(map[Place]bool, len(adj))
+
+	starts := make([]Place, 0, len(adj))
+	for p := range adj {
+		starts = append(starts, p)
+	}
+	sort.Sort(ByState(starts))
+
+	components := [][]Place{}
+	for _, start := range starts {
+		if seen[start] {
+			continue
+		}
+
+		// breadth first search from start
+		seen[start] = true
+		comp := []Place{}
+		queue := []Place{start}
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+			comp = append(comp, current)
+			for _, n := range adj[current] {
+				if !seen[n] {
+					seen[n] = true
+					queue = append(queue, n)
+				}
+			}
+		}
+
+		sort.Sort(ByState(comp))
+		components = append(components, comp)
+	}
+
+	sort.SliceStable(components, func(i, j int) bool {
+		return len(components[i]) > len(components[j])
+	})
+	return components
+}
+
+// Bridges finds the connections whose removal would disconnect the graph
+// (or one of its components) using Tarjan's algorithm. Each bridge is a pair
+// of places sorted by ByState, and the bridges are sorted by their first
+// then second place.
+//
+// https://en.wikipedia.org/wiki/Bridge_(graph_theory)
+func (g Graph) Bridges() [][2]Place {
+	bridges, _ := g.tarjan()
+	return bridges
+}
+
+// ArticulationPoints finds the places whose removal would disconnect the
+// graph (or one of its components) using Tarjan's algorithm. The places are
+// sorted by ByState.
+//
+// https://en.wikipedia.org/wiki/Biconnected_component
+func (g Graph) ArticulationPoints() []Place {
+	_, cuts := g.tarjan()
+	return cuts
+}
+
+// tarjan does a depth first search of the graph, recording for each place
+// the order in which it was discovered and the earliest discovered place
+// reachable from its subtree through at most one "back edge". Those numbers
+// identify the bridges and articulation points.
+func (g Graph) tarjan() (bridges [][2]Place, cuts []Place) {
+	adj := g.adjacency()
+	disc := make(map[Place]int, len(adj)) // discovery order, starting at 1
+	low := make(map[Place]int, len(adj))
+	iscut := map[Place]bool{}
+	order := 0
+
+	var visit func(p, parent Place, root bool)
+	visit = func(p, parent Place, root bool) {
+		order++
+		disc[p] = order
+		low[p] = order
+		children := 0
+
+		for _, n := range adj[p] {
+			if disc[n] == 0 {
+				children++
+				visit(n, p, false)
+				if low[n] < low[p] {
+					low[p] = low[n]
+				}
+				// nothing in n's subtree can reach p or above without p-n
+				if low[n] > disc[p] {
+					b := [2]Place{p, n}
+					if lessByState(n, p) {
+						b = [2]Place{n, p}
+					}
+					bridges = append(bridges, b)
+				}
+				// nothing in n's subtree can reach above p without p
+				if !root && low[n] >= disc[p] {
+					iscut[p] = true
+				}
+			} else if n != parent && disc[n] < low[p] {
+				low[p] = disc[n]
+			}
+		}
+
+		// the root of the search is a cut only if it has multiple subtrees
+		if root && children > 1 {
+			iscut[p] = true
+		}
+	}
+
+	starts := make([]Place, 0, len(adj))
+	for p := range adj {
+		starts = append(starts, p)
+	}
+	sort.Sort(ByState(starts))
+	for _, p := range starts {
+		if disc[p] == 0 {
+			visit(p, Place{}, true)
+		}
+	}
+
+	sort.Slice(bridges, func(i, j int) bool {
+		if bridges[i][0] != bridges[j][0] {
+			return lessByState(bridges[i][0], bridges[j][0])
+		}
+		return lessByState(bridges[i][1], bridges[j][1])
+	})
+	for p := range iscut {
+		cuts = append(cuts, p)
+	}
+	sort.Sort(ByState(cuts))
+
+	return bridges, cuts
+}
@@ -0,0 +1,155 @@
+package hwy
+
+import (
+	"container/heap"
+	"math"
+)
+
+// SearchStats holds information about the work done by
+// BidirectionalShortestPath.
+type SearchStats struct {
+	Forward  int   // nodes expanded by the search from the origin
+	Backward int   // nodes expanded by the search from the destination
+	Meet     Place // place where the two searches met
+}
+
+// Expanded is the total number of nodes expanded by both searches.
+func (s SearchStats) Expanded() int {
+	return s.Forward + s.Backward
+}
+
+// frontier is one half of a bidirectional search.
+type frontier struct {
+	nodes    PathMap
+	pq       *placeQueue
+	expanded int
+}
+
+func newFrontier(g Graph, start Place) *frontier {
+	f := &frontier{
+		nodes: make(PathMap, len(g)),
+		pq:    &placeQueue{{place: start, prio: 0}},
+	}
+	for k := range g {
+		f.nodes[k] = pdata{Dist: math.Inf(1)}
+	}
+	f.nodes[start] = pdata{Dist: 0}
+	return f
+}
+
+// top discards stale entries from the front of the queue and returns the
+// smallest tentative distance remaining, or infinity if the queue is empty.
+func (f *frontier) top() float64 {
+	for f.pq.Len() > 0 {
+		item := (*f.pq)[0]
+		if !f.nodes[item.place].visited {
+			return item.prio
+		}
+		heap.Pop(f.pq)
+	}
+	return math.Inf(1)
+}
+
+// BidirectionalShortestPath finds the shortest path between orig and dest by
+// running Dijkstra's algorithm from both ends at once, stopping when the two
+// searches meet in the middle. The path and cost are the same as given by
+// `ShortestPath(orig, by).Path(dest)`, including nil and 0 if there is no path.
+//
+// The graph is assumed to be undirected (see RawIsUndirected), so the
+// backward search walks the edges leading into each place by looking up the
+// reverse of each of the place's own edges.
+//
+// https://en.wikipedia.org/wiki/Bidirectional_search
+func (g Graph) BidirectionalShortestPath(orig, dest Place, by Accessor) (path []Place, cost float64, stats SearchStats) {
+	_, okOrig := g[orig]
+	_, okDest := g[dest]
+	if !okOrig || !okDest || orig == dest {
+		return
+	}
+
+	fwd := newFrontier(g, orig)
+	bwd := newFrontier(g, dest)
+
+	// best is the cost of the shortest path found so far through meet
+	best := math.Inf(1)
+	var meet Place
+
+	// relax updates the distance to n in 'this' search, and checks if the
+	// path through n joins up with the 'other' search more cheaply.
+	relax := func(this, other *frontier, current, n Place, cost float64) {
+		d := this.nodes[n]
+		if d.visited {
+			return
+		}
+		tentative := this.nodes[current].Dist + cost
+		if d.Dist > tentative {
+			d.Dist = tentative
+			d.parent = current
+			d.Hops = this.nodes[current].Hops + 1
+			this.nodes[n] = d
+			heap.Push(this.pq, pqItem{place: n, prio: tentative})
+
+			if through := tentative + other.nodes[n].Dist; through < best {
+				best = through
+				meet = n
+			}
+		}
+	}
+
+	for {
+		topFwd, topBwd := fwd.top(), bwd.top()
+		// no path shorter than best can be found once the two frontiers
+		// together are at least as far as best. This also covers both queues
+		// being exhausted.
+		if topFwd+topBwd >= best || math.IsInf(topFwd, 1) || math.IsInf(topBwd, 1) {
+			break
+		}
+
+		// advance whichever search has the closer frontier
+		if topFwd <= topBwd {
+			current := heap.Pop(fwd.pq).(pqItem).place
+			d := fwd.nodes[current]
+			d.visited = true
+			fwd.nodes[current] = d
+			fwd.expanded++
+
+			for n, w := range g[current] {
+				relax(fwd, bwd, current, n, by(w))
+			}
+		} else {
+			current := heap.Pop(bwd.pq).(pqItem).place
+			d := bwd.nodes[current]
+			d.visited = true
+			bwd.nodes[current] = d
+			bwd.expanded++
+
+			for n := range g[current] {
+				// walk the edge n -> current "backwards"
+				if w, ok := g.Edge(n, current); ok {
+					relax(bwd, fwd, current, n, by(w))
+				}
+			}
+		}
+	}
+
+	stats = SearchStats{Forward: fwd.expanded, Backward: bwd.expanded, Meet: meet}
+	if math.IsInf(best, 1) {
+		return nil, 0, stats
+	}
+
+	// build path from orig to meet to dest
+	hops := fwd.nodes[meet].Hops + bwd.nodes[meet].Hops
+	path = make([]Place, hops+1, hops+1) // +1 to include origin in path
+	n := meet
+	for i := fwd.nodes[meet].Hops; i >= 0; i-- {
+		path[i] = n
+		n = fwd.nodes[n].parent
+	}
+	n = meet
+	for i := fwd.nodes[meet].Hops; i < len(path); i++ {
+		path[i] = n
+		n = bwd.nodes[n].parent
+	}
+
+	return path, best, stats
+}
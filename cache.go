@@ -0,0 +1,232 @@
+package hwy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cache stores the results of geocoding and distance requests so that
+// running the pipeline again doesn't repeat (and pay for) the same requests.
+// Places are keyed by normalized (lower case) "city,state", and Weights by a
+// pair of those keys for the origin and destination. Use Geocoder and
+// DistanceProvider to wrap the real implementations with the cache.
+type Cache struct {
+	Places  map[string]Place  `json:"places"`
+	Weights map[string]Weight `json:"weights"`
+
+	refresh map[string]bool // place keys to request again
+
+	placeHits, placeMisses   int
+	weightHits, weightMisses int
+}
+
+// NewCache creates an empty Cache.
+func NewCache() *Cache {
+	return &Cache{
+		Places:  map[string]Place{},
+		Weights: map[string]Weight{},
+		refresh: map[string]bool{},
+	}
+}
+
+// LoadCache reads a Cache from the JSON file at path. If the file doesn't
+// exist, an empty Cache is returned.
+func LoadCache(path string) (*Cache, error) {
+	c := NewCache()
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("cache %s: %w", path, err)
+	}
+	if c.Places == nil {
+		c.Places = map[string]Place{}
+	}
+	if c.Weights == nil {
+		c.Weights = map[string]Weight{}
+	}
+	return c, nil
+}
+
+// Save writes the cache to a JSON file at path. The file is replaced only
+// once it has been completely written.
+func (c *Cache) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", " ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Refresh forces the places named "city,state" (any case) to be requested
+// again, along with the distances to and from them, instead of being taken
+// from the cache.
+func (c *Cache) Refresh(names ...string) {
+	for key := range refreshKeys(names) {
+		c.refresh[key] = true
+	}
+}
+
+// refreshKeys gives the place keys of names in the form "city,state". Names
+// not in that form are ignored.
+func refreshKeys(names []string) map[string]bool {
+	keys := map[string]bool{}
+	for _, name := range names {
+		parts := strings.Split(name, minorSep)
+		if len(parts) == 2 {
+			keys[placeKey(parts[0], parts[1])] = true
+		}
+	}
+	return keys
+}
+
+// withoutRefreshed gives a copy of g without the places in refresh (by
+// place key) and the edges to and from them, so that they are requested
+// again instead of being reused from g.
+func withoutRefreshed(g Graph, refresh map[string]bool) Graph {
+	if len(refresh) == 0 {
+		return g
+	}
+	kept := make(Graph, len(g))
+	for orig, dests := range g {
+		if refresh[placeKey(orig.City, orig.State)] {
+			continue
+		}
+		em := make(EdgeMap, len(dests))
+		for dest, w := range dests {
+			if !refresh[placeKey(dest.City, dest.State)] {
+				em[dest] = w
+			}
+		}
+		kept[orig] = em
+	}
+	return kept
+}
+
+// PrintStats writes the number of cache hits and misses to w.
+func (c *Cache) PrintStats(w io.Writer) {
+	fmt.Fprintf(w, "cache: %d place hits, %d misses; %d distance hits, %d misses.\n",
+		c.placeHits, c.placeMisses, c.weightHits, c.weightMisses)
+}
+
+// pairKey makes the key for the Weight of the edge from orig to dest.
+func pairKey(orig, dest Place) string {
+	return placeKey(orig.City, orig.State) + majorSep + placeKey(dest.City, dest.State)
+}
+
+// place gives the cached Place for city and state, if there is one and it is
+// not to be refreshed.
+func (c *Cache) place(city, state string) (Place, bool) {
+	key := placeKey(city, state)
+	p, ok := c.Places[key]
+	if !ok || c.refresh[key] {
+		return Place{}, false
+	}
+	p.City, p.State = city, state
+	return p, true
+}
+
+// weight gives the cached Weight for the edge from orig to dest, if there is
+// one and neither place is to be refreshed.
+func (c *Cache) weight(orig, dest Place) (Weight, bool) {
+	w, ok := c.Weights[pairKey(orig, dest)]
+	if !ok || c.refresh[placeKey(orig.City, orig.State)] || c.refresh[placeKey(dest.City, dest.State)] {
+		return Weight{}, false
+	}
+	return w, true
+}
+
+// Geocoder wraps geo so that places are looked up in the cache first, and
+// places found by geo are added to the cache.
+func (c *Cache) Geocoder(geo Geocoder) Geocoder {
+	return &cachedGeocoder{cache: c, geo: geo}
+}
+
+type cachedGeocoder struct {
+	cache *Cache
+	geo   Geocoder
+}
+
+func (cg *cachedGeocoder) Geocode(ctx context.Context, city, state string) (Place, error) {
+	if p, ok := cg.cache.place(city, state); ok {
+		cg.cache.placeHits++
+		return p, nil
+	}
+	cg.cache.placeMisses++
+
+	p, err := cg.geo.Geocode(ctx, city, state)
+	if err != nil {
+		return p, err
+	}
+	cg.cache.Places[placeKey(city, state)] = p
+	return p, nil
+}
+
+// DistanceProvider wraps dp so that the Weights of edges are looked up in the
+// cache first, and only the remaining edges are given to dp. Weights found by
+// dp are added to the cache, except zero Weights, which indicate that dp
+// failed to find them.
+func (c *Cache) DistanceProvider(dp DistanceProvider) DistanceProvider {
+	return &cachedDistances{cache: c, dp: dp}
+}
+
+type cachedDistances struct {
+	cache *Cache
+	dp    DistanceProvider
+}
+
+func (cd *cachedDistances) Weigh(ctx context.Context, g Graph) error {
+	// collect the edges not in the cache into a separate graph for dp
+	misses := Graph{}
+	for orig, dests := range g {
+		for dest := range dests {
+			if w, ok := cd.cache.weight(orig, dest); ok {
+				cd.cache.weightHits++
+				dests[dest] = w
+				continue
+			}
+			cd.cache.weightMisses++
+			if misses[orig] == nil {
+				misses[orig] = EdgeMap{}
+			}
+			misses[orig][dest] = Weight{}
+		}
+	}
+	if len(misses) == 0 {
+		return nil
+	}
+
+	err := cd.dp.Weigh(ctx, misses)
+	for orig, dests := range misses {
+		for dest, w := range dests {
+			g[orig][dest] = w
+			if w != (Weight{}) {
+				cd.cache.Weights[pairKey(orig, dest)] = w
+			}
+		}
+	}
+	return err
+}
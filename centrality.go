@@ -0,0 +1,204 @@
+package hwy
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Centrality holds measures of how "central" a place is to travel in the
+// graph. Costs are in the units of the Accessor used to calculate them.
+type Centrality struct {
+	Place Place
+
+	// Degree is the number of places directly connected to the place.
+	Degree int
+
+	// Closeness is the inverse of the average cost to reach the other places,
+	// scaled by the fraction of places that are reachable at all.
+	Closeness float64
+
+	// Betweenness is the fraction of shortest paths between pairs of other
+	// places that pass through the place.
+	Betweenness float64
+
+	// Eccentricity is the cost to reach the farthest reachable place.
+	Eccentricity float64
+}
+
+// CentralityReport holds the centrality measures for every place in the
+// graph, and the graph's diameter and radius.
+type CentralityReport struct {
+	Places []Centrality
+
+	// Diameter is the largest eccentricity of any place.
+	Diameter float64
+
+	// Radius is the smallest eccentricity of any place.
+	Radius float64
+}
+
+// CentralityMetric selects one of the measures in Centrality.
+type CentralityMetric int
+
+// CentralityMetrics for CentralityReport.Sort.
+const (
+	Betweenness CentralityMetric = iota
+	Closeness
+	Degree
+	Eccentricity
+)
+
+// Sort orders the places in the report from most to least central according
+// to metric. For Eccentricity, that means smallest first.
+func (r CentralityReport) Sort(metric CentralityMetric) {
+	less := map[CentralityMetric]func(a, b Centrality) bool{
+		Betweenness:  func(a, b Centrality) bool { return a.Betweenness > b.Betweenness },
+		Closeness:    func(a, b Centrality) bool { return a.Closeness > b.Closeness },
+		Degree:       func(a, b Centrality) bool { return a.Degree > b.Degree },
+		Eccentricity: func(a, b Centrality) bool { return a.Eccentricity < b.Eccentricity },
+	}[metric]
+
+	sort.SliceStable(r.Places, func(i, j int) bool {
+		return less(r.Places[i], r.Places[j])
+	})
+}
+
+// PrettyPrint writes a table of the first n places in the report to w, as
+// well as the diameter and radius. If n < 1, all places are written.
+func (r CentralityReport) PrettyPrint(w io.Writer, n int) {
+	if n < 1 || n > len(r.Places) {
+		n = len(r.Places)
+	}
+
+	fmt.Fprintf(w, "diameter %g, radius %g\n", r.Diameter, r.Radius)
+	fmt.Fprintf(w, "%4s  %-16s%3s%7s%12s%12s%13s\n",
+		"#", "city", "st", "degree", "closeness", "betweenness", "eccentricity")
+	for i, c := range r.Places[:n] {
+		fmt.Fprintf(w, "%4d  %-16s%3s%7d%12.4g%12.4f%13.1f\n",
+			i+1, c.Place.City, c.Place.State, c.Degree, c.Closeness, c.Betweenness, c.Eccentricity)
+	}
+}
+
+// Centrality calculates the degree, closeness, betweenness, and eccentricity
+// of every place in the graph, using shortest paths according to 'by'. The
+// graph is treated as undirected, with the cost of each connection being the
+// cheaper of its two directions. Betweenness is calculated with Brandes'
+// algorithm. The places in the report are sorted by Betweenness.
+//
+// https://en.wikipedia.org/wiki/Centrality
+//
+// https://en.wikipedia.org/wiki/Betweenness_centrality#Algorithms
+func (g Graph) Centrality(by Accessor) CentralityReport {
+	adj := g.adjacency()
+	n := len(adj)
+
+	places := make([]Place, 0, n)
+	for p := range adj {
+		places = append(places, p)
+	}
+	sort.Sort(ByState(places))
+
+	betweenness := make(map[Place]float64, n)
+	report := CentralityReport{
+		Places: make([]Centrality, 0, n),
+		Radius: math.Inf(1),
+	}
+
+	for _, s := range places {
+		dist, sigma, preds, order := g.brandesSearch(s, adj, by)
+
+		// accumulate the dependency of s on each place, from the farthest
+		// places back towards s.
+		delta := make(map[Place]float64, len(order))
+		for i := len(order) - 1; i >= 0; i-- {
+			w := order[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				betweenness[w] += delta[w]
+			}
+		}
+
+		c := Centrality{Place: s, Degree: len(adj[s])}
+		var sum float64
+		for _, d := range dist {
+			sum += d
+			if d > c.Eccentricity {
+				c.Eccentricity = d
+			}
+		}
+		if reached := float64(len(dist) - 1); sum > 0 {
+			c.Closeness = reached / sum * reached / float64(n-1)
+		}
+
+		report.Places = append(report.Places, c)
+		report.Diameter = math.Max(report.Diameter, c.Eccentricity)
+		report.Radius = math.Min(report.Radius, c.Eccentricity)
+	}
+
+	// every pair was counted from both ends, and is normalized by the number
+	// of pairs not including the place itself.
+	pairs := float64(n-1) * float64(n-2) / 2
+	for i := range report.Places {
+		c := &report.Places[i]
+		c.Betweenness = betweenness[c.Place] / 2
+		if pairs > 0 {
+			c.Betweenness /= pairs
+		}
+	}
+	if n == 0 {
+		report.Radius = 0
+	}
+
+	report.Sort(Betweenness)
+	return report
+}
+
+// brandesSearch is Dijkstra's algorithm modified to count the number of
+// shortest paths (sigma) to each place and record all the predecessors on
+// those paths. dist only has places reachable from s, and order lists them
+// in the order they were visited.
+func (g Graph) brandesSearch(s Place, adj map[Place][]Place, by Accessor) (dist, sigma map[Place]float64, preds map[Place][]Place, order []Place) {
+	const epsilon = 1e-9 // for considering two path costs equal
+
+	dist = map[Place]float64{s: 0}
+	sigma = map[Place]float64{s: 1}
+	preds = map[Place][]Place{}
+	visited := map[Place]bool{}
+
+	pq := &placeQueue{{place: s, prio: 0}}
+	for pq.Len() > 0 {
+		current := heap.Pop(pq).(pqItem).place
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		order = append(order, current)
+
+		for _, n := range adj[current] {
+			if visited[n] {
+				continue
+			}
+			tentative := dist[current] + g.undirectedCost(current, n, by)
+			d, ok := dist[n]
+			switch {
+			case !ok || tentative < d-epsilon:
+				// strictly shorter path
+				dist[n] = tentative
+				sigma[n] = sigma[current]
+				preds[n] = []Place{current}
+				heap.Push(pq, pqItem{place: n, prio: tentative})
+			case math.Abs(tentative-d) <= epsilon:
+				// another path of the same cost
+				sigma[n] += sigma[current]
+				preds[n] = append(preds[n], current)
+			}
+		}
+	}
+
+	return
+}
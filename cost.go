@@ -0,0 +1,122 @@
+package hwy
+
+import (
+	"fmt"
+	"io"
+)
+
+// Prices are the costs in dollars of the Google Maps APIs used by the
+// pipeline. Google bills geocoding per request and the Distance Matrix per
+// element (one origin and destination pair).
+type Prices struct {
+	Geocoding      float64 // per 1000 requests
+	DistanceMatrix float64 // per 1000 elements
+}
+
+// DefaultPrices are Google's list prices at the lowest volume tier.
+var DefaultPrices = Prices{Geocoding: 5, DistanceMatrix: 5}
+
+// CostEstimate counts the requests the pipeline would make to process a raw
+// file, and what they would cost.
+type CostEstimate struct {
+	Places int // places in the raw file
+	Edges  int // directed edges in the raw file
+
+	GeocodeRequests int // places to be geocoded
+	GeocodeReused   int // places found in the cache or previous graph
+
+	DistanceRequests int // Distance Matrix requests, as batched by GoogleDistances
+	DistanceElements int // edges to be weighed
+	DistanceReused   int // edges found in the cache or previous graph
+
+	Prices Prices
+}
+
+// GeocodeCost gives the estimated cost of the geocoding requests.
+func (e CostEstimate) GeocodeCost() float64 {
+	return float64(e.GeocodeRequests) * e.Prices.Geocoding / 1000
+}
+
+// DistanceCost gives the estimated cost of the Distance Matrix requests.
+func (e CostEstimate) DistanceCost() float64 {
+	return float64(e.DistanceElements) * e.Prices.DistanceMatrix / 1000
+}
+
+// Cost gives the total estimated cost.
+func (e CostEstimate) Cost() float64 {
+	return e.GeocodeCost() + e.DistanceCost()
+}
+
+// PrettyPrint writes a summary of the estimate to w.
+func (e CostEstimate) PrettyPrint(w io.Writer) {
+	fmt.Fprintf(w, "raw data: %d places, %d edges.\n", e.Places, e.Edges)
+	fmt.Fprintf(w, "geocoding: %d requests (%d reused) at $%.2f/1000 = $%.2f\n",
+		e.GeocodeRequests, e.GeocodeReused, e.Prices.Geocoding, e.GeocodeCost())
+	fmt.Fprintf(w, "distance matrix: %d requests, %d elements (%d reused) at $%.2f/1000 = $%.2f\n",
+		e.DistanceRequests, e.DistanceElements, e.DistanceReused, e.Prices.DistanceMatrix, e.DistanceCost())
+	fmt.Fprintf(w, "total: $%.2f\n", e.Cost())
+}
+
+// EstimateCost parses the raw data from r and counts the geocoding and
+// Distance Matrix requests that the pipeline would make to process it,
+// without making any of them. Places and edges that are in cache or prev (a
+// previous result of the pipeline, as used by UpdateRaw) are not counted as
+// requests, unless they are to be refreshed (see Cache.Refresh). cache and
+// prev may be nil.
+//
+// An error is returned if the raw data is not undirected, in which case the
+// pipeline would make no requests at all.
+func EstimateCost(r io.Reader, prev Graph, cache *Cache, prices Prices) (CostEstimate, error) {
+	rg := parseRawGraph(r)
+	if !rawIsUndirected(rg) {
+		return CostEstimate{}, errUndirected
+	}
+
+	if cache == nil {
+		cache = NewCache()
+	}
+
+	// the cache's refreshed places aren't reused from prev either
+	known := map[string]bool{}
+	for orig, dests := range withoutRefreshed(prev, cache.refresh) {
+		known[placeKey(orig.City, orig.State)] = true
+		for dest := range dests {
+			known[pairKey(orig, dest)] = true
+		}
+	}
+
+	// only the city and state are needed to look up the cache
+	place := func(rp rawPlace) Place { return Place{City: rp.city, State: rp.state} }
+
+	e := CostEstimate{Places: len(rg), Prices: prices}
+	for rp, neighbors := range rg {
+		orig := place(rp)
+		if _, ok := cache.place(rp.city, rp.state); ok || known[placeKey(rp.city, rp.state)] {
+			e.GeocodeReused++
+		} else {
+			e.GeocodeRequests++
+		}
+
+		// a neighbor listed twice is still one edge in the EdgeMap
+		seen := map[rawPlace]bool{}
+		elements := 0
+		for _, n := range neighbors {
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			e.Edges++
+			dest := place(n)
+			if _, ok := cache.weight(orig, dest); ok || known[pairKey(orig, dest)] {
+				e.DistanceReused++
+			} else {
+				elements++
+			}
+		}
+		// one request per origin for each batch of MaxMatrixDestinations
+		e.DistanceRequests += (elements + MaxMatrixDestinations - 1) / MaxMatrixDestinations
+		e.DistanceElements += elements
+	}
+
+	return e, nil
+}
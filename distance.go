@@ -0,0 +1,230 @@
+package hwy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"googlemaps.github.io/maps"
+)
+
+// DistanceProvider finds the travel distance and time of connections between
+// places.
+type DistanceProvider interface {
+	// Weigh sets the Weight of every edge in g.
+	Weigh(ctx context.Context, g Graph) error
+}
+
+// DistanceError is a failure to find the Weight of the edge from Orig to
+// Dest.
+type DistanceError struct {
+	Orig, Dest Place
+
+	// Status is the Distance Matrix element status, such as ZERO_RESULTS or
+	// NOT_FOUND, if the request succeeded but the element did not.
+	Status string
+
+	// Err is the reason the request failed, if it did.
+	Err error
+}
+
+func (e *DistanceError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s -> %s: %v", e.Orig.Name(), e.Dest.Name(), e.Err)
+	}
+	return fmt.Sprintf("%s -> %s: %s", e.Orig.Name(), e.Dest.Name(), e.Status)
+}
+
+func (e *DistanceError) Unwrap() error {
+	return e.Err
+}
+
+// DistanceErrors is a list of the edges a DistanceProvider failed to weigh.
+// The Weights of those edges are left unchanged, while the rest are set.
+type DistanceErrors []*DistanceError
+
+func (de DistanceErrors) Error() string {
+	strs := make([]string, len(de))
+	for i, e := range de {
+		strs[i] = e.Error()
+	}
+	return fmt.Sprintf("%d distance errors:\n%s", len(de), strings.Join(strs, "\n"))
+}
+
+// MaxMatrixDestinations is the most destinations the Distance Matrix API
+// allows in one request.
+const MaxMatrixDestinations = 25
+
+// GoogleDistances is a DistanceProvider that uses the Google Maps Distance
+// Matrix API.
+//
+// NOTE: the Google Maps API requires a key and may also incure useage charges.
+type GoogleDistances struct {
+	client *maps.Client
+
+	// Retries is how many more times a request is made if it fails for a
+	// reason that may be temporary, such as a network error or going over the
+	// query limit.
+	Retries int
+
+	// Backoff is how long to wait before the first retry. The wait doubles
+	// for each retry after that.
+	Backoff time.Duration
+}
+
+// NewGoogleDistances creates a GoogleDistances using apikey, which retries
+// failed requests 3 times starting after 1 second. opts are passed on to the
+// maps.Client, for example maps.WithRateLimit to set the most requests per
+// second, or maps.WithBaseURL to use a different server.
+func NewGoogleDistances(apikey string, opts ...maps.ClientOption) (*GoogleDistances, error) {
+	client, err := maps.NewClient(append([]maps.ClientOption{maps.WithAPIKey(apikey)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &GoogleDistances{client: client, Retries: 3, Backoff: time.Second}, nil
+}
+
+// Weigh gets the Weights of the edges in g with one Distance Matrix request
+// for each place and up to MaxMatrixDestinations of its neighbors. Several
+// origins are never combined into one request, since Google bills for every
+// origin and destination pair in a request and most of those pairs would not
+// be edges.
+//
+// Edges that can't be weighed, because their request failed or Google gave a
+// status other than OK for them, are left unchanged and returned as
+// DistanceErrors. If ctx is cancelled or its deadline passes, Weigh stops and
+// returns ctx.Err(), with only the edges weighed so far set.
+func (gd *GoogleDistances) Weigh(ctx context.Context, g Graph) error {
+	errs := DistanceErrors{}
+
+	origs := g.Places()
+	sort.Sort(ByState(origs))
+	for _, orig := range origs {
+		dests := make([]Place, 0, len(g[orig]))
+		for d := range g[orig] {
+			dests = append(dests, d)
+		}
+		sort.Sort(ByState(dests))
+
+		for len(dests) > 0 {
+			n := len(dests)
+			if n > MaxMatrixDestinations {
+				n = MaxMatrixDestinations
+			}
+			batch := dests[:n]
+			dests = dests[n:]
+
+			elems, err := gd.request(ctx, orig, batch)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				for _, d := range batch {
+					errs = append(errs, &DistanceError{Orig: orig, Dest: d, Err: err})
+				}
+				continue
+			}
+
+			for i, d := range batch {
+				if elems[i].Status != "OK" {
+					errs = append(errs, &DistanceError{Orig: orig, Dest: d, Status: elems[i].Status})
+					continue
+				}
+				g[orig][d] = Weight{Distance: float64(elems[i].Distance.Meters), TravelTime: elems[i].Duration}
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// request makes one Distance Matrix request from orig to dests, retrying if
+// it fails for a temporary reason. Gives the elements for each of dests in
+// the same order.
+func (gd *GoogleDistances) request(ctx context.Context, orig Place, dests []Place) ([]*maps.DistanceMatrixElement, error) {
+	req := &maps.DistanceMatrixRequest{Origins: []string{orig.Name()}}
+	for _, d := range dests {
+		req.Destinations = append(req.Destinations, d.Name())
+	}
+
+	wait := gd.Backoff
+	for try := 0; ; try++ {
+		resp, err := gd.client.DistanceMatrix(ctx, req)
+		if err == nil {
+			if len(resp.Rows) != 1 {
+				return nil, fmt.Errorf("got %d rows for 1 origin", len(resp.Rows))
+			}
+			if len(resp.Rows[0].Elements) != len(dests) {
+				return nil, fmt.Errorf("got %d elements for %d destinations", len(resp.Rows[0].Elements), len(dests))
+			}
+			return resp.Rows[0].Elements, nil
+		}
+		if try >= gd.Retries || !temporary(ctx, err) {
+			return nil, err
+		}
+
+		fmt.Fprintf(os.Stderr, "%s: %v. retrying in %s.\n", orig.Name(), err, wait)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		wait *= 2
+	}
+}
+
+// temporary is true if err, from a maps.Client request, may not happen if the
+// request is made again. The maps package reports the API's status as an
+// error "maps: STATUS - message", and other errors are from the network.
+func temporary(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "maps: ") {
+		return true
+	}
+	return strings.Contains(msg, "OVER_QUERY_LIMIT") || strings.Contains(msg, "UNKNOWN_ERROR")
+}
+
+// Estimator is an offline DistanceProvider that estimates the road distance
+// between two places from the great-circle distance between them, and the
+// travel time from an average speed.
+type Estimator struct {
+	// Circuity is the ratio of road distance to great-circle distance.
+	Circuity float64
+
+	// Speed is the average speed in miles per hour.
+	Speed float64
+}
+
+// DefaultEstimator has the median circuity and average speed of the edges in
+// the US highway data.
+var DefaultEstimator = Estimator{Circuity: 1.13, Speed: 64}
+
+// Estimate gives the estimated Weight of the connection from orig to dest.
+// Distances are rounded to the meter and times to the second, like Google's.
+func (e Estimator) Estimate(orig, dest Place) Weight {
+	meters := sphericalLawOfCos(orig.Latitude, orig.Longitude, dest.Latitude, dest.Longitude) * e.Circuity
+	hours := meters * MetersToMiles / e.Speed
+	return Weight{
+		Distance:   float64(int64(meters + 0.5)),
+		TravelTime: time.Duration(hours * float64(time.Hour)).Round(time.Second),
+	}
+}
+
+// Weigh sets the Weight of every edge in g to its estimate.
+func (e Estimator) Weigh(ctx context.Context, g Graph) error {
+	for orig, dests := range g {
+		for dest := range dests {
+			dests[dest] = e.Estimate(orig, dest)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package hwy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"googlemaps.github.io/maps"
+)
+
+// matrixServer is a stand-in for the Distance Matrix API. Every element is
+// 1000 meters and 60 seconds, except destinations whose names start with one
+// of the keys of statuses, which get that status instead. The first
+// overLimit requests are answered with OVER_QUERY_LIMIT.
+type matrixServer struct {
+	statuses  map[string]string
+	overLimit int
+
+	mu       sync.Mutex
+	requests [][]string // destinations of each request, including refused ones
+}
+
+func (ms *matrixServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	dests := strings.Split(r.URL.Query().Get("destinations"), "|")
+
+	ms.mu.Lock()
+	ms.requests = append(ms.requests, dests)
+	refuse := len(ms.requests) <= ms.overLimit
+	ms.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if refuse {
+		fmt.Fprint(w, `{"status": "OVER_QUERY_LIMIT", "error_message": "slow down"}`)
+		return
+	}
+
+	elems := make([]string, len(dests))
+	for i, d := range dests {
+		elems[i] = `{"status": "OK", "distance": {"value": 1000, "text": "1 km"}, "duration": {"value": 60, "text": "1 min"}}`
+		for prefix, status := range ms.statuses {
+			if strings.HasPrefix(d, prefix) {
+				elems[i] = fmt.Sprintf(`{"status": %q}`, status)
+			}
+		}
+	}
+	fmt.Fprintf(w, `{"status": "OK", "rows": [{"elements": [%s]}]}`, strings.Join(elems, ","))
+}
+
+// newTestDistances starts ms and gives a GoogleDistances that uses it, and a
+// func to stop ms.
+func newTestDistances(t *testing.T, ms *matrixServer) (*GoogleDistances, func()) {
+	srv := httptest.NewServer(ms)
+	gd, err := NewGoogleDistances("key", maps.WithBaseURL(srv.URL), maps.WithRateLimit(0))
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	gd.Backoff = time.Millisecond
+	return gd, srv.Close
+}
+
+// starGraph makes a graph with edges from one origin to each of dests.
+func starGraph(dests ...string) (Graph, Place) {
+	orig := Place{City: "Origin", State: "WA"}
+	g := Graph{orig: EdgeMap{}}
+	for _, d := range dests {
+		g[orig][Place{City: d, State: "WA"}] = Weight{}
+	}
+	return g, orig
+}
+
+func TestGoogleDistancesBatches(t *testing.T) {
+	ms := &matrixServer{}
+	gd, stop := newTestDistances(t, ms)
+	defer stop()
+
+	dests := []string{}
+	for i := 0; i < MaxMatrixDestinations+5; i++ {
+		dests = append(dests, fmt.Sprintf("Dest %02d", i))
+	}
+	g, orig := starGraph(dests...)
+
+	if err := gd.Weigh(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ms.requests) != 2 {
+		t.Fatalf("got %d requests, want 2", len(ms.requests))
+	}
+	if n := len(ms.requests[0]); n != MaxMatrixDestinations {
+		t.Errorf("first request has %d destinations, want %d", n, MaxMatrixDestinations)
+	}
+	if n := len(ms.requests[1]); n != 5 {
+		t.Errorf("second request has %d destinations, want 5", n)
+	}
+
+	want := Weight{Distance: 1000, TravelTime: time.Minute}
+	for dest, w := range g[orig] {
+		if w != want {
+			t.Errorf("%s: got %v, want %v", dest.Name(), w, want)
+		}
+	}
+}
+
+func TestGoogleDistancesElementErrors(t *testing.T) {
+	ms := &matrixServer{statuses: map[string]string{
+		"Nowhere": "ZERO_RESULTS",
+		"Unknown": "NOT_FOUND",
+	}}
+	gd, stop := newTestDistances(t, ms)
+	defer stop()
+
+	g, orig := starGraph("Good", "Nowhere", "Unknown")
+	untouched := Weight{Distance: -1}
+	g[orig][Place{City: "Nowhere", State: "WA"}] = untouched
+	g[orig][Place{City: "Unknown", State: "WA"}] = untouched
+
+	err := gd.Weigh(context.Background(), g)
+	var derrs DistanceErrors
+	if !errors.As(err, &derrs) {
+		t.Fatalf("got error %v, want DistanceErrors", err)
+	}
+
+	statuses := map[string]string{}
+	for _, e := range derrs {
+		statuses[e.Dest.City] = e.Status
+	}
+	if len(derrs) != 2 || statuses["Nowhere"] != "ZERO_RESULTS" || statuses["Unknown"] != "NOT_FOUND" {
+		t.Errorf("got errors %v", derrs)
+	}
+
+	for dest, w := range g[orig] {
+		want := untouched
+		if dest.City == "Good" {
+			want = Weight{Distance: 1000, TravelTime: time.Minute}
+		}
+		if w != want {
+			t.Errorf("%s: got %v, want %v", dest.Name(), w, want)
+		}
+	}
+}
+
+func TestGoogleDistancesRetries(t *testing.T) {
+	ms := &matrixServer{overLimit: 2}
+	gd, stop := newTestDistances(t, ms)
+	defer stop()
+	gd.Backoff = 20 * time.Millisecond
+
+	g, orig := starGraph("Dest")
+	start := time.Now()
+	if err := gd.Weigh(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ms.requests) != 3 {
+		t.Errorf("got %d requests, want 3", len(ms.requests))
+	}
+	// waits 20ms then 40ms
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("retried after %s, want backoff of at least 60ms", elapsed)
+	}
+	if w := g[orig][Place{City: "Dest", State: "WA"}]; w.Distance != 1000 {
+		t.Errorf("got %v after retrying", w)
+	}
+
+	// gives up once the retries are used
+	ms = &matrixServer{overLimit: 10}
+	gd, stop2 := newTestDistances(t, ms)
+	defer stop2()
+	gd.Retries = 1
+	g, _ = starGraph("Dest")
+	err := gd.Weigh(context.Background(), g)
+	var derrs DistanceErrors
+	if !errors.As(err, &derrs) || len(derrs) != 1 || derrs[0].Err == nil {
+		t.Errorf("got error %v, want a DistanceError for the failed request", err)
+	}
+	if len(ms.requests) != 2 {
+		t.Errorf("got %d requests, want 2", len(ms.requests))
+	}
+}
+
+func TestGoogleDistancesCancel(t *testing.T) {
+	ms := &matrixServer{}
+	gd, stop := newTestDistances(t, ms)
+	defer stop()
+
+	g, orig := starGraph("Dest")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := gd.Weigh(ctx, g); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+	if w := g[orig][Place{City: "Dest", State: "WA"}]; w != (Weight{}) {
+		t.Errorf("got %v, want the Weight unchanged", w)
+	}
+
+	// cancelled while waiting to retry
+	ms = &matrixServer{overLimit: 10}
+	gd, stop2 := newTestDistances(t, ms)
+	defer stop2()
+	gd.Backoff = time.Minute
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := gd.Weigh(ctx, g); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
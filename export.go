@@ -0,0 +1,243 @@
+package hwy
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// These functions write the graph in formats for drawing tools such as
+// GraphViz and yEd. Each connection between two places is written once, even
+// if the graph has an edge in both directions.
+
+// EdgeLabel selects what WriteDOT and WriteGraphML use to label edges.
+type EdgeLabel int
+
+// EdgeLabels for ExportOptions.
+const (
+	LabelMiles EdgeLabel = iota // distance in miles
+	LabelTime                   // travel time
+	LabelNone                   // no label
+)
+
+// ExportOptions controls the output of WriteDOT and WriteGraphML.
+type ExportOptions struct {
+	// Label is what to label the edges with.
+	Label EdgeLabel
+
+	// Highlight is a path, such as from PathMap.Path, whose places and edges
+	// will be highlighted.
+	Highlight []Place
+
+	// Scale is the number of position units per degree of longitude or
+	// latitude. If 0, 10 is used.
+	Scale float64
+}
+
+// scale gives the Scale to use.
+func (opts ExportOptions) scale() float64 {
+	if opts.Scale == 0 {
+		return 10
+	}
+	return opts.Scale
+}
+
+// label gives the text to label w with.
+func (opts ExportOptions) label(w Weight) string {
+	switch opts.Label {
+	case LabelMiles:
+		return fmt.Sprintf("%.1fmi", w.Distance*MetersToMiles)
+	case LabelTime:
+		return w.TravelTime.Round(time.Minute).String()
+	}
+	return ""
+}
+
+// highlighted gives the set of places and connections in opts.Highlight.
+func (opts ExportOptions) highlighted() (places map[Place]bool, edges map[edge]bool) {
+	places = map[Place]bool{}
+	edges = map[edge]bool{}
+	for i, p := range opts.Highlight {
+		places[p] = true
+		if i > 0 {
+			prev := opts.Highlight[i-1]
+			edges[edge{prev, p}] = true
+			edges[edge{p, prev}] = true
+		}
+	}
+	return
+}
+
+// edgeWeight gives the Weight of the connection e, preferring the direction
+// from e.a to e.b if it exists.
+func (g Graph) edgeWeight(e edge) Weight {
+	if w, ok := g.Edge(e.a, e.b); ok {
+		return w
+	}
+	return g[e.b][e.a]
+}
+
+// WriteDOT writes the graph to w in the GraphViz DOT language as an undirected
+// graph. Each place is a node whose "pos" is its longitude and latitude
+// times opts.Scale, so the graph is drawn as a map by `neato -n` or `fdp`.
+//
+// https://graphviz.org/doc/info/lang.html
+func (g Graph) WriteDOT(w io.Writer, opts ExportOptions) error {
+	scale := opts.scale()
+	hlPlaces, hlEdges := opts.highlighted()
+
+	// ew remembers the first write error, so it's only checked at the end
+	ew := &errWriter{w: w}
+	ew.printf("graph hwy {\n")
+	ew.printf("\tnode [shape=point, fontsize=8];\n")
+	ew.printf("\tedge [fontsize=6];\n")
+
+	places := g.Places()
+	sort.Sort(ByState(places))
+	for _, p := range places {
+		attrs := fmt.Sprintf("xlabel=%s, pos=\"%.3f,%.3f!\"",
+			strconv.Quote(p.City+", "+p.State), p.Longitude*scale, p.Latitude*scale)
+		if hlPlaces[p] {
+			attrs += ", color=red, width=0.1"
+		}
+		ew.printf("\t%s [%s];\n", strconv.Quote(p.Name()), attrs)
+	}
+
+	for _, e := range g.undirectedEdges() {
+		attrs := fmt.Sprintf("label=%s", strconv.Quote(opts.label(g.edgeWeight(e))))
+		if hlEdges[e] {
+			attrs += ", color=red, penwidth=3"
+		}
+		ew.printf("\t%s -- %s [%s];\n", strconv.Quote(e.a.Name()), strconv.Quote(e.b.Name()), attrs)
+	}
+
+	ew.printf("}\n")
+	return ew.err
+}
+
+// errWriter wraps an io.Writer and remembers the first error writing to it.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, a ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, a...)
+	}
+}
+
+// types for GraphML
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+var graphMLKeys = []graphMLKey{
+	{ID: "city", For: "node", Name: "city", Type: "string"},
+	{ID: "state", For: "node", Name: "state", Type: "string"},
+	{ID: "lat", For: "node", Name: "latitude", Type: "double"},
+	{ID: "lon", For: "node", Name: "longitude", Type: "double"},
+	{ID: "x", For: "node", Name: "x", Type: "double"},
+	{ID: "y", For: "node", Name: "y", Type: "double"},
+	{ID: "dist", For: "edge", Name: "distance", Type: "double"},
+	{ID: "time", For: "edge", Name: "travel_time", Type: "string"},
+	{ID: "label", For: "edge", Name: "label", Type: "string"},
+	{ID: "highlight", For: "all", Name: "highlight", Type: "boolean"},
+}
+
+// WriteGraphML writes the graph to w in the GraphML format as an undirected
+// graph. Each place is a node with its city, state, latitude, and longitude,
+// as well as x and y positions (longitude and latitude times opts.Scale).
+// Each connection has its distance in meters, travel time, and label.
+//
+// http://graphml.graphdrawing.org/
+func (g Graph) WriteGraphML(w io.Writer, opts ExportOptions) error {
+	scale := opts.scale()
+	hlPlaces, hlEdges := opts.highlighted()
+	float := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  graphMLKeys,
+		Graph: graphMLGraph{ID: "hwy", EdgeDefault: "undirected"},
+	}
+
+	places := g.Places()
+	sort.Sort(ByState(places))
+	for _, p := range places {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: p.Name(),
+			Data: []graphMLData{
+				{Key: "city", Value: p.City},
+				{Key: "state", Value: p.State},
+				{Key: "lat", Value: float(p.Latitude)},
+				{Key: "lon", Value: float(p.Longitude)},
+				{Key: "x", Value: float(p.Longitude * scale)},
+				{Key: "y", Value: float(p.Latitude * scale)},
+				{Key: "highlight", Value: strconv.FormatBool(hlPlaces[p])},
+			},
+		})
+	}
+
+	for _, e := range g.undirectedEdges() {
+		wt := g.edgeWeight(e)
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: e.a.Name(),
+			Target: e.b.Name(),
+			Data: []graphMLData{
+				{Key: "dist", Value: float(wt.Distance)},
+				{Key: "time", Value: wt.TravelTime.String()},
+				{Key: "label", Value: opts.label(wt)},
+				{Key: "highlight", Value: strconv.FormatBool(hlEdges[e])},
+			},
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
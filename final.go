@@ -2,9 +2,12 @@ package hwy
 
 import (
 	"bufio"
+	"container/heap"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -248,10 +251,12 @@ const (
 func sphericalLawOfCos(lat1, lon1, lat2, lon2 float64) float64 {
 	lat1 *= degtorad
 	lat2 *= degtorad
-	return earthradius * math.Acos(
-		math.Sin(lat1)*math.Sin(lat2)+
-			math.Cos(lat1)*math.Cos(lat2)*
-				math.Cos((lon2-lon1)*degtorad))
+	cos := math.Sin(lat1)*math.Sin(lat2) +
+		math.Cos(lat1)*math.Cos(lat2)*
+			math.Cos((lon2-lon1)*degtorad)
+	// rounding can push cos slightly above 1 for (nearly) identical points,
+	// which would make acos NaN.
+	return earthradius * math.Acos(math.Min(cos, 1))
 }
 
 //
@@ -293,93 +298,164 @@ func (pm PathMap) Path(dest Place) (path []Place, sum float64) {
 }
 
 // ShortestPath finds the shortest paths between orig and all other vertices
-// using Dijkstra's algorithm.
+// using Dijkstra's algorithm. Returns nil if orig is not in the graph.
 //
 // https://en.wikipedia.org/wiki/Dijkstra%27s_algorithm
 func (g Graph) ShortestPath(orig Place, by Accessor) PathMap {
+	pm, _ := g.search(orig, nil, by, nil)
+	return pm
+}
+
+// ShortestPathTo is like ShortestPath, but stops searching as soon as the
+// shortest path to dest is known. Only the data for dest (and other visited
+// places) in the resulting PathMap is final, so it should generally only be
+// used with `Path(dest)`. Returns nil if orig is not in the graph.
+func (g Graph) ShortestPathTo(orig, dest Place, by Accessor) PathMap {
+	pm, _ := g.search(orig, &dest, by, nil)
+	return pm
+}
+
+// search does the work for ShortestPath, ShortestPathTo, and AStar. If dest
+// is nil, the paths to all vertices are found. If h is nil, the search is
+// plain Dijkstra. Also returns the number of nodes expanded (visited).
+func (g Graph) search(orig Place, dest *Place, by Accessor, h Heuristic) (nodes PathMap, expanded int) {
+	if _, ok := g[orig]; !ok {
+		return nil, 0
+	}
+	var target Place
+	if dest != nil {
+		target = *dest
+	}
+	if h == nil || dest == nil {
+		// a heuristic is meaningless without a destination
+		h = func(Place, Place) float64 { return 0 }
+	}
 
 	inf := math.Inf(1)
-	none := Place{} // zero val
-	var d pdata     // temp var for data
-
-	// 1. mark all nodes unvisitied. create a set of all unvisited nodes
-	// call the unvisited set
-	// 2. assign to every node a tentative distance value: zero for initial node
-	// and infinity ("unvisited") for all others. Set initial node as current.
-	nodes := make(PathMap, len(g))
+	var d pdata // temp var for data
+
+	// 1. assign to every node a tentative distance value: zero for initial node
+	// and infinity ("unvisited") for all others.
+	nodes = make(PathMap, len(g))
 	for k := range g {
 		nodes[k] = pdata{Dist: inf}
 	}
-
-	current := orig
-	d = nodes[current]
-	d.Dist = 0
-	nodes[current] = d
-
-	found := false // aka done
-
-	for !found {
-		// fmt.Println("current", current, nodes[current])
-		if current == none {
-			return nil
-		}
-
-		// 3. for the current node, consider all its unvisited neighbors and
-		// calculate their tentative distances through the current node. Compare
-		// the newly calculated tentative distance to the currently assigned value
-		// and assign the smaller value.
-		for n, w := range g[current] {
-			if !nodes[n].visited { // n in unvisited set
-				tentative := nodes[current].Dist + by(w)
-				d = nodes[n]
-				if d.Dist > tentative {
-					d.Dist = tentative
-					d.parent = current
-					d.Hops = nodes[d.parent].Hops + 1
-					nodes[n] = d
-				}
-			}
+	nodes[orig] = pdata{Dist: 0}
+
+	// 2. the priority queue holds nodes with their tentative distances (plus
+	// the heuristic estimate to dest for A*). a node may be in the queue more
+	// than once, in which case the stale (larger) entries are skipped when
+	// they are popped.
+	pq := &placeQueue{{place: orig, prio: 0}}
+
+	for pq.Len() > 0 {
+		// 3. select the unvisited node with the smallest tentative distance
+		// as "current". if the queue is exhausted, all reachable nodes have
+		// been visited and the algorithm is finished.
+		current := heap.Pop(pq).(pqItem).place
+		if nodes[current].visited {
+			continue
 		}
 
-		// 4. when we are done considering all the unvisited neighbors of the
-		// current node, mark the current node as visited and remove it from the
-		// unvisited set. A visited node will never be checked again.
+		// 4. mark the current node as visited. A visited node will never be
+		// checked again. stop early if current is the destination.
 		d = nodes[current]
 		d.visited = true
 		nodes[current] = d
+		expanded++
+		if dest != nil && current == target {
+			break
+		}
 
-		// 5. A) if all nodes are marked visited (unvisited set is empty)
-		// OR B) if the smallest tentative distance among nodes in the unvisited set
-		// is infinity (no path possible)
-		// The algorithm is finished.
-		// TODO: termination case B
-		unvisitedcount := 0
-		for _, d := range nodes {
-			if !d.visited {
-				unvisitedcount++
+		// 5. for the current node, consider all its unvisited neighbors and
+		// calculate their tentative distances through the current node. If
+		// the new distance is smaller, update the neighbor and enqueue it.
+		for n, w := range g[current] {
+			d = nodes[n]
+			if d.visited {
+				continue
+			}
+			tentative := nodes[current].Dist + by(w)
+			if d.Dist > tentative {
+				d.Dist = tentative
+				d.parent = current
+				d.Hops = nodes[current].Hops + 1
+				nodes[n] = d
+				heap.Push(pq, pqItem{place: n, prio: tentative + h(n, target)})
 			}
 		}
+	}
 
-		found = unvisitedcount == 0
-		if found {
-			continue
-		}
+	return nodes, expanded
+}
 
-		// 6. Otherwise, select the unvisited node that is marked with the smallest
-		// tentative value, set it as the "current" and go back to step 3.
-		minDist := inf // pos infinity
-		minPlace := Place{}
-		for node, d := range nodes {
-			if !d.visited && d.Dist < minDist {
-				minDist = d.Dist
-				minPlace = node
-			}
-		}
-		current = minPlace
-		found = minDist == inf // termination case 5B above
+// pqItem is an entry in placeQueue.
+type pqItem struct {
+	place Place
+	prio  float64
+}
+
+// placeQueue is a min-heap of places ordered by priority. Use with
+// `container/heap`.
+type placeQueue []pqItem
+
+func (q placeQueue) Len() int            { return len(q) }
+func (q placeQueue) Less(i, j int) bool  { return q[i].prio < q[j].prio }
+func (q placeQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *placeQueue) Push(x interface{}) { *q = append(*q, x.(pqItem)) }
+func (q *placeQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
+
+//
+//
+// A* search
+//
+//
+
+// Heuristic is a function that estimates the cost of the cheapest path from
+// a Place to another, in the same units as the Accessor used for the search.
+// AStar never revisits a place, so to find the shortest path the estimate
+// must be "consistent": h(u) <= cost(u,v) + h(v) for every edge u->v. This
+// also means it never exceeds the actual cost (is "admissible"), but an
+// admissible heuristic that isn't consistent may give a longer path.
+type Heuristic func(from, to Place) float64
+
+// DefaultMaxSpeed is a maximum average speed, in miles per hour, that is
+// faster than that of any edge in the US highway data.
+const DefaultMaxSpeed = 80.0
+
+// StraightLine is a consistent Heuristic for use with Dist. It gives the
+// great-circle distance in meters between two places.
+var StraightLine Heuristic = func(from, to Place) float64 {
+	return sphericalLawOfCos(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+}
+
+// StraightLineTime creates a Heuristic for use with Time. It gives the time in
+// minutes to travel the great-circle distance between two places at maxSpeed
+// miles per hour. The Heuristic is only consistent if no edge in the graph
+// has a faster average speed than maxSpeed. See DefaultMaxSpeed.
+func StraightLineTime(maxSpeed float64) Heuristic {
+	return func(from, to Place) float64 {
+		miles := StraightLine(from, to) * MetersToMiles
+		return miles / maxSpeed * 60
 	}
+}
 
-	return nodes
+// AStar finds the shortest path between orig and dest using the A* search
+// algorithm, guided by the heuristic h. The resulting PathMap is used the same
+// as one returned by ShortestPathTo. The number of nodes expanded (visited)
+// by the search is also returned, and can be compared with the number of
+// places in the graph to gauge the effectiveness of h. Returns nil if orig is
+// not in the graph.
+//
+// https://en.wikipedia.org/wiki/A*_search_algorithm
+func (g Graph) AStar(orig, dest Place, by Accessor, h Heuristic) (pm PathMap, expanded int) {
+	return g.search(orig, &dest, by, h)
 }
 
 //
@@ -392,6 +468,9 @@ func (g Graph) ShortestPath(orig Place, by Accessor) PathMap {
 // entry in the graph. Lines beginning with "#" are ignored ascomments, and
 // blank lines are skipped. Line format is:
 // `<place:city,state,lat,lon>;<place>,<weight:distance,time>;<place>,<weight>;...`
+//
+// Malformed numbers are silently treated as zero. Use ParseGraphStrict to
+// find problems in the input.
 func ParseGraph(r io.Reader) Graph {
 	s := bufio.NewScanner(r)
 
@@ -431,6 +510,162 @@ func ParsePlace(str string) (p Place) {
 	return
 }
 
+// ParseError describes a malformed record found by ParseGraphStrict.
+type ParseError struct {
+	Line  int // line number, starting at 1
+	Field int // `majorSep` separated field in the line, starting at 1
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d, field %d: %s", e.Line, e.Field, e.Err)
+}
+
+// Unwrap gives the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors is a list of all the malformed records found by
+// ParseGraphStrict, in the order they appear in the input.
+type ParseErrors []*ParseError
+
+func (pe ParseErrors) Error() string {
+	strs := make([]string, len(pe))
+	for i, e := range pe {
+		strs[i] = e.Error()
+	}
+	return fmt.Sprintf("%d parse errors:\n%s", len(pe), strings.Join(strs, "\n"))
+}
+
+// ParseGraphStrict parses the same format as ParseGraph, but checks that each
+// record is well formed instead of ignoring (or panicking on) bad data. Bad
+// numbers and durations, missing or extra fields, vertices whose name (in any
+// case) appears more than once, repeated edges, and edges to places that are never declared as
+// vertices are all reported. Parsing continues after a bad record so that
+// all the problems are found at once, in which case the error is ParseErrors
+// and the graph contains whatever could be parsed.
+func ParseGraphStrict(r io.Reader) (Graph, error) {
+	s := bufio.NewScanner(r)
+
+	// record where things are so that later problems can be located
+	type location struct{ line, field int }
+	type declared struct {
+		line  int
+		place Place
+	}
+	vertexAt := map[string]declared{} // by placeKey, so case doesn't matter
+	edgesAt := map[location]Place{}
+
+	g := Graph{}
+	errs := ParseErrors{}
+	report := func(line, field int, err error) {
+		errs = append(errs, &ParseError{Line: line, Field: field, Err: err})
+	}
+
+	lineno := 0
+	for s.Scan() {
+		lineno++
+		line := s.Text()
+		// skip blank and comment
+		if len(line) == 0 || strings.TrimSpace(string(line[0])) == "#" {
+			continue
+		}
+
+		parts := strings.Split(line, majorSep)
+		vertex, err := parsePlaceStrict(strings.Split(parts[0], minorSep), 4)
+		if err != nil {
+			report(lineno, 1, err)
+			continue // can't do anything with edges to an unknown vertex
+		}
+		key := placeKey(vertex.City, vertex.State)
+		if prev, ok := vertexAt[key]; ok {
+			report(lineno, 1, fmt.Errorf("vertex %s already declared on line %d", vertex.Name(), prev.line))
+			continue
+		}
+		vertexAt[key] = declared{lineno, vertex}
+
+		edges := EdgeMap{}
+		for i, part := range parts[1:] {
+			field := i + 2 // 1 based, after the vertex
+			dparts := strings.Split(part, minorSep)
+			dest, err := parsePlaceStrict(dparts, 6)
+			if err != nil {
+				report(lineno, field, err)
+				continue
+			}
+			w := Weight{}
+			if w.Distance, err = strconv.ParseFloat(dparts[4], 64); err != nil {
+				report(lineno, field, fmt.Errorf("distance: %w", err))
+				continue
+			}
+			if w.TravelTime, err = time.ParseDuration(dparts[5]); err != nil {
+				report(lineno, field, fmt.Errorf("travel time: %w", err))
+				continue
+			}
+			if _, ok := edges[dest]; ok {
+				report(lineno, field, fmt.Errorf("repeated edge to %s", dest.Name()))
+				continue
+			}
+			edges[dest] = w
+			edgesAt[location{lineno, field}] = dest
+		}
+		g[vertex] = edges
+	}
+	if err := s.Err(); err != nil {
+		return g, err
+	}
+
+	// now that all vertices are known, check the edges
+	for loc, dest := range edgesAt {
+		if _, ok := g[dest]; ok {
+			continue
+		}
+		err := fmt.Errorf("edge to undeclared place %s", dest)
+		if v, ok := vertexAt[placeKey(dest.City, dest.State)]; ok {
+			err = fmt.Errorf("edge to %s does not match vertex on line %d (%s)", dest, v.line, v.place)
+		}
+		report(loc.line, loc.field, err)
+	}
+
+	if len(errs) == 0 {
+		return g, nil
+	}
+	sort.Slice(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Field < errs[j].Field
+	})
+	return g, errs
+}
+
+// parsePlaceStrict parses a Place from the `minorSep` separated fields of a
+// place, checking that there are exactly n fields.
+func parsePlaceStrict(fields []string, n int) (p Place, err error) {
+	switch {
+	case len(fields) < 4:
+		return p, fmt.Errorf("want %d comma separated values, got %d: missing place data", n, len(fields))
+	case len(fields) < n:
+		return p, fmt.Errorf("want %d comma separated values, got %d: missing weight", n, len(fields))
+	case len(fields) > n:
+		return p, fmt.Errorf("want %d comma separated values, got %d: extra data", n, len(fields))
+	}
+
+	p.City = fields[0]
+	p.State = fields[1]
+	if p.City == "" || p.State == "" {
+		return p, errors.New("missing city or state")
+	}
+	if p.Latitude, err = strconv.ParseFloat(fields[2], 64); err != nil {
+		return p, fmt.Errorf("latitude: %w", err)
+	}
+	if p.Longitude, err = strconv.ParseFloat(fields[3], 64); err != nil {
+		return p, fmt.Errorf("longitude: %w", err)
+	}
+	return p, nil
+}
+
 //
 //
 //
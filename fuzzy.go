@@ -0,0 +1,189 @@
+package hwy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// These functions find places whose names are similar but not identical, to
+// suggest corrections for misspelled names and to catch two spellings of the
+// same place in the raw data.
+
+// Match is a place whose name is similar to a name that was searched for.
+type Match struct {
+	Place Place
+
+	// Edits is the number of single letter insertions, deletions, or
+	// substitutions between the city names.
+	Edits int
+
+	// SameState is true if the place is in the state that was searched for.
+	SameState bool
+}
+
+// score ranks a match; lower is better. A place in another state ranks as if
+// its city name had one more edit.
+func (m Match) score() int {
+	if m.SameState {
+		return m.Edits
+	}
+	return m.Edits + 1
+}
+
+// maxEdits gives the most edits for which a city name is considered similar
+// to name. Short names allow fewer edits.
+func maxEdits(name string) int {
+	if n := len(name) / 4; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// editDistance gives the Levenshtein distance between a and b, ignoring case.
+//
+// https://en.wikipedia.org/wiki/Levenshtein_distance
+func editDistance(a, b string) int {
+	ra := []rune(strings.ToLower(a))
+	rb := []rune(strings.ToLower(b))
+
+	// only two rows of the table are needed
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			sub := prev[j-1]
+			if ra[i-1] != rb[j-1] {
+				sub++
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, sub)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// FuzzyFind finds up to n places whose city names are similar to city,
+// ordered from best to worst match. Matches are ranked by the edit distance
+// between city names, with places in state ranked ahead of places in other
+// states the same distance away. An exact match, if any, is first. If n < 1,
+// all matches are returned.
+func (g Graph) FuzzyFind(city, state string, n int) []Match {
+	city = strings.TrimSpace(city)
+	state = strings.TrimSpace(state)
+	limit := maxEdits(city)
+
+	matches := []Match{}
+	for p := range g {
+		edits := editDistance(city, p.City)
+		if edits > limit {
+			continue
+		}
+		matches = append(matches, Match{
+			Place:     p,
+			Edits:     edits,
+			SameState: strings.EqualFold(p.State, state),
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if si, sj := matches[i].score(), matches[j].score(); si != sj {
+			return si < sj
+		}
+		return lessByState(matches[i].Place, matches[j].Place)
+	})
+	if n > 0 && len(matches) > n {
+		matches = matches[:n]
+	}
+	return matches
+}
+
+// FindPlaceFuzzy is like FindPlace, but if there is no exact match it falls
+// back to the best match from FuzzyFind, as long as that match is in state
+// and no other match is as good. found is false if there is no such place.
+func (g Graph) FindPlaceFuzzy(city, state string) (match Place, found bool) {
+	if p, ok := g.FindPlace(city, state); ok {
+		return p, true
+	}
+
+	matches := g.FuzzyFind(city, state, 2)
+	if len(matches) == 0 || !matches[0].SameState {
+		return Place{}, false
+	}
+	if len(matches) == 2 && matches[1].score() == matches[0].score() {
+		return Place{}, false // ambiguous
+	}
+	return matches[0].Place, true
+}
+
+// NearDuplicate is a pair of places in raw data whose names are so similar
+// that they may be two spellings of the same place.
+type NearDuplicate struct {
+	A, B  string // "City,ST"
+	Edits int
+}
+
+func (d NearDuplicate) String() string {
+	return fmt.Sprintf("%s and %s are similar (%d edits)", d.A, d.B, d.Edits)
+}
+
+// FindNearDuplicates parses raw graph data from r and finds pairs of places,
+// as vertices or neighbors, in the same state whose city names are similar.
+// They are printed to Stderr and returned.
+func FindNearDuplicates(r io.Reader) []NearDuplicate {
+	dups := nearDuplicates(parseRawGraph(r))
+	for _, d := range dups {
+		fmt.Fprintln(os.Stderr, d)
+	}
+	return dups
+}
+
+// nearDuplicates finds pairs of places in rg in the same state whose city
+// names are similar.
+func nearDuplicates(rg rawGraph) []NearDuplicate {
+	seen := map[rawPlace]bool{}
+	for p, neighbors := range rg {
+		seen[p] = true
+		for _, n := range neighbors {
+			seen[n] = true
+		}
+	}
+	places := make([]rawPlace, 0, len(seen))
+	for p := range seen {
+		places = append(places, p)
+	}
+	sort.Slice(places, func(i, j int) bool { return lessRaw(places[i], places[j]) })
+
+	dups := []NearDuplicate{}
+	for i, a := range places {
+		for _, b := range places[i+1:] {
+			if a.state != b.state {
+				break // sorted by state
+			}
+			limit := maxEdits(a.city)
+			if l := maxEdits(b.city); l < limit {
+				limit = l
+			}
+			if edits := editDistance(a.city, b.city); edits <= limit {
+				dups = append(dups, NearDuplicate{A: a.String(), B: b.String(), Edits: edits})
+			}
+		}
+	}
+	return dups
+}
@@ -0,0 +1,130 @@
+package hwy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"googlemaps.github.io/maps"
+)
+
+// Geocoder finds the geographic location of a city.
+type Geocoder interface {
+	// Geocode gives the Place for city and state. The Place's City and State
+	// are the same as those given.
+	Geocode(ctx context.Context, city, state string) (Place, error)
+}
+
+// ErrNotFound is returned by a Geocoder that doesn't know a place.
+var ErrNotFound = errors.New("place not found")
+
+// placeKey makes a case insensitive key for a city and state.
+func placeKey(city, state string) string {
+	return strings.ToLower(strings.TrimSpace(city)) + minorSep + strings.ToLower(strings.TrimSpace(state))
+}
+
+// GoogleGeocoder is a Geocoder that uses the Google Maps Geocoding API.
+//
+// NOTE: the Google Maps API requires a key and may also incure useage charges.
+type GoogleGeocoder struct {
+	client *maps.Client
+}
+
+// NewGoogleGeocoder creates a GoogleGeocoder using apikey. opts are passed
+// on to the maps.Client, as for NewGoogleDistances.
+func NewGoogleGeocoder(apikey string, opts ...maps.ClientOption) (*GoogleGeocoder, error) {
+	client, err := maps.NewClient(append([]maps.ClientOption{maps.WithAPIKey(apikey)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &GoogleGeocoder{client: client}, nil
+}
+
+// Geocode requests the location of city, state from Google. If Google gives
+// more than one result, the first is used and the rest are printed to Stderr.
+func (gg *GoogleGeocoder) Geocode(ctx context.Context, city, state string) (Place, error) {
+	req := &maps.GeocodingRequest{Address: fmt.Sprintf("%s, %s", city, state)}
+	results, err := gg.client.Geocode(ctx, req)
+	if err != nil {
+		return Place{}, err
+	}
+	if len(results) < 1 {
+		return Place{}, ErrNotFound
+	}
+	if len(results) > 1 {
+		fmt.Fprintln(os.Stderr, city, state, "more than 1 result?", results)
+	}
+
+	return Place{
+		City:      city,
+		State:     state,
+		Latitude:  results[0].Geometry.Location.Lat,
+		Longitude: results[0].Geometry.Location.Lng,
+	}, nil
+}
+
+// Gazetteer is an offline Geocoder that looks places up in a list of known
+// locations. Keys are made with placeKey.
+type Gazetteer map[string]Place
+
+// Geocode finds city and state in the gazetteer, ignoring case. Returns
+// ErrNotFound if the place isn't known.
+func (gz Gazetteer) Geocode(ctx context.Context, city, state string) (Place, error) {
+	p, ok := gz[placeKey(city, state)]
+	if !ok {
+		return Place{}, ErrNotFound
+	}
+	p.City, p.State = city, state
+	return p, nil
+}
+
+// GazetteerFromGraph creates a Gazetteer containing the places in g, such as a
+// graph read from a previous run of the pipeline.
+func GazetteerFromGraph(g Graph) Gazetteer {
+	gz := make(Gazetteer, len(g))
+	for p := range g {
+		gz[placeKey(p.City, p.State)] = p
+	}
+	return gz
+}
+
+// ParseGazetteer reads a Gazetteer from r. Each line is a place in the format
+// `city name state@latitude longitude`, such as "Salt Lake City UT@40.760779
+// -111.891047" (the format of data/locations). Blank lines and lines starting
+// with # are ignored.
+func ParseGazetteer(r io.Reader) (Gazetteer, error) {
+	gz := Gazetteer{}
+
+	s := bufio.NewScanner(r)
+	lineno := 0
+	for s.Scan() {
+		lineno++
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		parts := strings.Split(line, "@")
+		coords := strings.Fields(parts[len(parts)-1])
+		if len(parts) != 2 || len(coords) != 2 || len(parts[0]) < 3 {
+			return nil, fmt.Errorf("gazetteer line %d: want \"city state@lat lon\", got %q", lineno, line)
+		}
+		rp := parseRawPlace(parts[0])
+		p := Place{City: rp.city, State: rp.state}
+		var err error
+		if p.Latitude, err = strconv.ParseFloat(coords[0], 64); err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: latitude: %w", lineno, err)
+		}
+		if p.Longitude, err = strconv.ParseFloat(coords[1], 64); err != nil {
+			return nil, fmt.Errorf("gazetteer line %d: longitude: %w", lineno, err)
+		}
+		gz[placeKey(p.City, p.State)] = p
+	}
+
+	return gz, s.Err()
+}
@@ -0,0 +1,200 @@
+package hwy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// These types and functions convert a Graph to and from GeoJSON, for use with
+// GIS tools. Each Place is a Point feature, and each connection between two
+// places is a LineString feature, holding the Weights for both directions.
+//
+// https://tools.ietf.org/html/rfc7946
+
+type geoCollection struct {
+	Type     string       `json:"type"`
+	Features []geoFeature `json:"features"`
+}
+
+type geoFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoGeometry     `json:"geometry"`
+	Properties json.RawMessage `json:"properties"`
+}
+
+type geoGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// geoPlace holds the properties of a Point feature.
+type geoPlace struct {
+	City  string `json:"city"`
+	State string `json:"state"`
+}
+
+// geoEdge holds the properties of a LineString feature. The "reverse"
+// properties are for the direction from To to From, and are left out if the
+// graph has no such edge.
+type geoEdge struct {
+	From          string  `json:"from"`
+	To            string  `json:"to"`
+	Distance      float64 `json:"distance_meters"`
+	Miles         float64 `json:"miles"`
+	TravelTime    string  `json:"travel_time"`
+	TravelMinutes float64 `json:"travel_minutes"`
+
+	ReverseDistance      *float64 `json:"reverse_distance_meters,omitempty"`
+	ReverseMiles         *float64 `json:"reverse_miles,omitempty"`
+	ReverseTravelTime    string   `json:"reverse_travel_time,omitempty"`
+	ReverseTravelMinutes *float64 `json:"reverse_travel_minutes,omitempty"`
+}
+
+// lonLat gives the GeoJSON position of p, which is longitude first.
+func lonLat(p Place) [2]float64 {
+	return [2]float64{p.Longitude, p.Latitude}
+}
+
+// newGeoFeature marshals the geometry coordinates and properties into a
+// feature.
+func newGeoFeature(geomType string, coords, props interface{}) (f geoFeature, err error) {
+	f.Type = "Feature"
+	f.Geometry.Type = geomType
+	if f.Geometry.Coordinates, err = json.Marshal(coords); err != nil {
+		return
+	}
+	f.Properties, err = json.Marshal(props)
+	return
+}
+
+// WriteGeoJSON writes the graph to w as a GeoJSON FeatureCollection. Each
+// place is a Point feature with "city" and "state" properties. Each
+// connection between two places is a LineString feature, with "from" and "to"
+// properties (Place.Name()) and the distance and travel time from "from" to
+// "to". If the graph also has the edge from "to" to "from", its distance and
+// travel time are included as the "reverse_" properties. ParseGeoJSON reads
+// the output back into the same graph.
+func (g Graph) WriteGeoJSON(w io.Writer) error {
+	doc := geoCollection{Type: "FeatureCollection", Features: []geoFeature{}}
+
+	places := g.Places()
+	sort.Sort(ByState(places))
+	for _, p := range places {
+		f, err := newGeoFeature("Point", lonLat(p), geoPlace{City: p.City, State: p.State})
+		if err != nil {
+			return err
+		}
+		doc.Features = append(doc.Features, f)
+	}
+
+	for _, e := range g.undirectedEdges() {
+		from, to := e.a, e.b
+		w, ok := g.Edge(from, to)
+		if !ok {
+			// only the other direction exists
+			from, to = to, from
+			w = g[from][to]
+		}
+
+		props := geoEdge{
+			From:          from.Name(),
+			To:            to.Name(),
+			Distance:      w.Distance,
+			Miles:         w.Distance * MetersToMiles,
+			TravelTime:    w.TravelTime.String(),
+			TravelMinutes: w.TravelTime.Minutes(),
+		}
+		if rw, ok := g.Edge(to, from); ok {
+			miles, minutes := rw.Distance*MetersToMiles, rw.TravelTime.Minutes()
+			props.ReverseDistance = &rw.Distance
+			props.ReverseMiles = &miles
+			props.ReverseTravelTime = rw.TravelTime.String()
+			props.ReverseTravelMinutes = &minutes
+		}
+
+		f, err := newGeoFeature("LineString", [][2]float64{lonLat(from), lonLat(to)}, props)
+		if err != nil {
+			return err
+		}
+		doc.Features = append(doc.Features, f)
+	}
+
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// ParseGeoJSON reads a GeoJSON FeatureCollection in the form written by
+// WriteGeoJSON from r. Point features become places, and LineString features
+// become edges between the places named by their "from" and "to" properties.
+// The coordinates of LineStrings and any other kinds of features are ignored.
+func ParseGeoJSON(r io.Reader) (Graph, error) {
+	var doc geoCollection
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("geojson: want FeatureCollection, got %q", doc.Type)
+	}
+
+	g := Graph{}
+	byName := map[string]Place{}
+
+	// places first, so that edges can refer to them
+	for i, f := range doc.Features {
+		if f.Geometry.Type != "Point" {
+			continue
+		}
+		var coords [2]float64
+		if err := json.Unmarshal(f.Geometry.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("geojson: feature %d: %w", i, err)
+		}
+		var props geoPlace
+		if err := json.Unmarshal(f.Properties, &props); err != nil {
+			return nil, fmt.Errorf("geojson: feature %d: %w", i, err)
+		}
+
+		p := Place{City: props.City, State: props.State, Longitude: coords[0], Latitude: coords[1]}
+		if _, ok := byName[p.Name()]; ok {
+			return nil, fmt.Errorf("geojson: feature %d: %s is repeated", i, p.Name())
+		}
+		byName[p.Name()] = p
+		g[p] = EdgeMap{}
+	}
+
+	for i, f := range doc.Features {
+		if f.Geometry.Type != "LineString" {
+			continue
+		}
+		var props geoEdge
+		if err := json.Unmarshal(f.Properties, &props); err != nil {
+			return nil, fmt.Errorf("geojson: feature %d: %w", i, err)
+		}
+		from, ok := byName[props.From]
+		if !ok {
+			return nil, fmt.Errorf("geojson: feature %d: unknown place %q", i, props.From)
+		}
+		to, ok := byName[props.To]
+		if !ok {
+			return nil, fmt.Errorf("geojson: feature %d: unknown place %q", i, props.To)
+		}
+
+		w := Weight{Distance: props.Distance}
+		var err error
+		if w.TravelTime, err = time.ParseDuration(props.TravelTime); err != nil {
+			return nil, fmt.Errorf("geojson: feature %d: %w", i, err)
+		}
+		g[from][to] = w
+
+		if props.ReverseDistance != nil {
+			rw := Weight{Distance: *props.ReverseDistance}
+			if rw.TravelTime, err = time.ParseDuration(props.ReverseTravelTime); err != nil {
+				return nil, fmt.Errorf("geojson: feature %d: %w", i, err)
+			}
+			g[to][from] = rw
+		}
+	}
+
+	return g, nil
+}
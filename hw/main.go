@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -8,19 +10,14 @@ import (
 	"strings"
 
 	"github.com/quillaja/hwy"
+	"github.com/quillaja/hwy/maps"
+	"github.com/quillaja/hwy/render"
+	gmaps "googlemaps.github.io/maps"
 )
 
-var apikey string
-
 func main() {
 	cmd := argN(1, "")
 
-	b, err := ioutil.ReadFile("KEY")
-	if err != nil {
-		panic(err)
-	}
-	apikey = string(b)
-
 	switch cmd {
 
 	case "find":
@@ -30,7 +27,11 @@ func main() {
 			name := argN(3, "none")
 
 			parts := strings.Split(name, ",") // requires comma separated
-			fmt.Println(g.FindPlace(parts[0], parts[1]))
+			p, found := g.FindPlace(parts[0], parts[1])
+			fmt.Println(p, found)
+			if !found {
+				printSuggestions(g, parts[0], parts[1])
+			}
 
 		case "loc":
 			g := hwy.ParseGraph(os.Stdin)
@@ -41,20 +42,304 @@ func main() {
 			fmt.Println(g.FindWithin(lat, lon, dist))
 
 		case "path":
+			// hw find path "CITY NAME,STATE" "CITY NAME,STATE" [...]
 			g := hwy.ParseGraph(os.Stdin)
-			origIn := strings.Split(argN(3, ""), ",") // assume "CITY NAME,STATE"
-			destIn := strings.Split(argN(4, ""), ",")
-			orig, _ := g.FindPlace(origIn[0], origIn[1])
-			dest, _ := g.FindPlace(destIn[0], destIn[1])
-			fmt.Printf("shortest path between %s and %s:\n", orig.Name(), dest.Name())
-			res := g.ShortestPath(orig, hwy.Dist)
-			fmt.Println(res.Path(dest))
+			stops := []hwy.Place{}
+			names := []string{}
+			for _, name := range os.Args[3:] {
+				p := findPlace(g, name)
+				stops = append(stops, p)
+				names = append(names, p.Name())
+			}
+			fmt.Printf("shortest path between %s:\n", strings.Join(names, " and "))
+
+			it, err := g.RouteVia(hwy.Dist, stops...)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			it.PrettyPrint(os.Stdout)
+		}
+	case "analyze":
+		g := hwy.ParseGraph(os.Stdin)
+
+		comps := g.Components()
+		fmt.Printf("%d connected component(s):\n", len(comps))
+		for i, comp := range comps {
+			fmt.Printf("  component %d (%d places):\n", i+1, len(comp))
+			for _, p := range comp {
+				fmt.Printf("\t%s, %s\n", p.City, p.State)
+			}
+		}
+
+		bridges := g.Bridges()
+		fmt.Printf("%d bridge(s):\n", len(bridges))
+		for _, b := range bridges {
+			fmt.Printf("\t%s, %s -- %s, %s\n", b[0].City, b[0].State, b[1].City, b[1].State)
+		}
+
+		cuts := g.ArticulationPoints()
+		fmt.Printf("%d articulation point(s):\n", len(cuts))
+		for _, p := range cuts {
+			fmt.Printf("\t%s, %s\n", p.City, p.State)
+		}
+
+	case "validate":
+		// hw validate [flags] < final
+		fs := flag.NewFlagSet("validate", flag.ExitOnError)
+		all := fs.Bool("all", false, "list every edge, not only the flagged ones")
+		opts := hwy.DefaultValidation
+		fs.Float64Var(&opts.MinCircuity, "min-circuity", opts.MinCircuity, "flag edges with a lower ratio of road to straight line distance")
+		fs.Float64Var(&opts.MaxCircuity, "max-circuity", opts.MaxCircuity, "flag edges with a higher ratio of road to straight line distance")
+		fs.Float64Var(&opts.MinSpeed, "min-speed", opts.MinSpeed, "flag edges with a lower average speed in mph")
+		fs.Float64Var(&opts.MaxSpeed, "max-speed", opts.MaxSpeed, "flag edges with a higher average speed in mph")
+		fs.Float64Var(&opts.MaxAsymmetry, "max-asymmetry", opts.MaxAsymmetry, "flag edges whose distance or time differ from the reverse edge's by more than this fraction")
+		fs.Parse(os.Args[2:])
+
+		g := hwy.ParseGraph(os.Stdin)
+		g.Validate(opts).PrettyPrint(os.Stdout, *all)
+
+	case "centrality":
+		g := hwy.ParseGraph(os.Stdin)
+		by := hwy.Dist
+		if argN(2, "dist") == "time" {
+			by = hwy.Time
+		}
+		top, _ := strconv.Atoi(argN(3, "10"))
+		metric := map[string]hwy.CentralityMetric{
+			"betweenness":  hwy.Betweenness,
+			"closeness":    hwy.Closeness,
+			"degree":       hwy.Degree,
+			"eccentricity": hwy.Eccentricity,
+		}[argN(4, "betweenness")] // defaults to betweenness if unknown
+
+		report := g.Centrality(by)
+		report.Sort(metric)
+		report.PrettyPrint(os.Stdout, top)
+
+	case "tour":
+		g := hwy.ParseGraph(os.Stdin)
+		by := hwy.Dist
+		if argN(2, "dist") == "time" {
+			by = hwy.Time
+		}
+		stops := []hwy.Place{}
+		for _, name := range os.Args[3:] {
+			stops = append(stops, findPlace(g, name))
+		}
+
+		tour, err := g.PlanTour(stops, by, hwy.TourAuto)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		for _, leg := range tour.Legs {
+			orig, dest := leg.Places[0], leg.Places[len(leg.Places)-1]
+			fmt.Printf("%s to %s: %.1fmi %s\n", orig.Name(), dest.Name(), leg.Miles(), leg.TravelTime)
+			for _, p := range leg.Places[1 : len(leg.Places)-1] {
+				fmt.Printf("\tvia %s\n", p.Name())
+			}
 		}
+		fmt.Printf("total: %.1fmi %s\n", tour.Miles(), tour.TravelTime)
+
+	case "export":
+		// hw export (geojson|dot|graphml) [miles|time] ["CITY NAME,STATE" ...]
+		g := hwy.ParseGraph(os.Stdin)
+		opts := hwy.ExportOptions{}
+		if argN(3, "miles") == "time" {
+			opts.Label = hwy.LabelTime
+		}
+		if len(os.Args) > 4 {
+			// highlight the route through the given places
+			opts.Highlight = routeThrough(g, os.Args[4:])
+		}
+
+		var err error
+		switch argN(2, "") {
+		case "geojson":
+			err = g.WriteGeoJSON(os.Stdout)
+		case "dot":
+			err = g.WriteDOT(os.Stdout, opts)
+		case "graphml":
+			err = g.WriteGraphML(os.Stdout, opts)
+		default:
+			err = fmt.Errorf("unknown export format %q", argN(2, ""))
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+	case "render":
+		// hw render (svg|png) [flags] ["CITY NAME,STATE" ...]
+		fs := flag.NewFlagSet("render", flag.ExitOnError)
+		width := fs.Int("width", 1000, "image width in pixels")
+		height := fs.Int("height", 1000, "image height in pixels")
+		bounds := fs.String("bounds", "", "area to draw as \"minlon,minlat,maxlon,maxlat\" (default fits the graph)")
+		grid := fs.Bool("grid", false, "draw lines every 10 degrees")
+		labels := fs.Bool("labels", false, "label places")
+		outlines := stringList{}
+		fs.Var(&outlines, "outline", "state or country outline `file` (.json or .txt) (repeatable)")
+		fs.Parse(os.Args[3:])
+
+		g := hwy.ParseGraph(os.Stdin)
+		m := render.NewMap(*width, *height, render.GraphBounds(g, 1), g)
+		m.Grid = *grid
+		m.Labels = *labels
+		if *bounds != "" {
+			b := render.Bounds{}
+			if _, err := fmt.Sscanf(*bounds, "%g,%g,%g,%g", &b.MinLon, &b.MinLat, &b.MaxLon, &b.MaxLat); err != nil {
+				fmt.Fprintln(os.Stderr, "bad bounds:", err)
+				os.Exit(1)
+			}
+			m.Bounds = b
+		}
+		for _, name := range outlines {
+			m.Outlines = append(m.Outlines, readOutline(name))
+		}
+		if fs.NArg() > 0 {
+			m.Route = routeThrough(g, fs.Args())
+		}
+
+		var err error
+		switch argN(2, "") {
+		case "svg":
+			err = m.WriteSVG(os.Stdout)
+		case "png":
+			err = m.WritePNG(os.Stdout)
+		default:
+			err = fmt.Errorf("unknown image format %q", argN(2, ""))
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
 	case "pipeline":
-		hwy.ConvertRaw(os.Stdin, os.Stdout, apikey)
+		// hw pipeline [flags] < raw > final
+		fs := flag.NewFlagSet("pipeline", flag.ExitOnError)
+		gazetteer := fs.String("gazetteer", "", "geocode offline using locations in `file` (such as data/locations) instead of Google")
+		estimate := fs.Bool("estimate", false, "estimate distances and times offline instead of using Google")
+		circuity := fs.Float64("circuity", hwy.DefaultEstimator.Circuity, "ratio of road to straight line distance for -estimate")
+		speed := fs.Float64("speed", hwy.DefaultEstimator.Speed, "average speed in mph for -estimate")
+		cachefile := fs.String("cache", "", "reuse and save geocoding and distance results in `file`")
+		prevfile := fs.String("prev", "", "only request places and edges not already in the final graph `file` (such as data/data)")
+		rate := fs.Int("rate", 10, "most Google requests per second")
+		retries := fs.Int("retries", 3, "times to retry a Google distance request that failed for a temporary reason")
+		dryrun := fs.Bool("dry-run", false, "only print the number of API requests that would be made and their estimated cost")
+		geoprice := fs.Float64("geocode-price", hwy.DefaultPrices.Geocoding, "dollars per 1000 geocoding requests for -dry-run")
+		distprice := fs.Float64("distance-price", hwy.DefaultPrices.DistanceMatrix, "dollars per 1000 distance matrix elements for -dry-run")
+		refresh := stringList{}
+		fs.Var(&refresh, "refresh", "request \"CITY NAME,STATE\" again instead of using the cache or -prev (repeatable)")
+		fs.Parse(os.Args[2:])
+
+		if *dryrun {
+			prices := hwy.Prices{Geocoding: *geoprice, DistanceMatrix: *distprice}
+			if *gazetteer != "" {
+				prices.Geocoding = 0 // offline
+			}
+			if *estimate {
+				prices.DistanceMatrix = 0 // offline
+			}
+
+			cache := hwy.NewCache()
+			if *cachefile != "" {
+				var err error
+				cache, err = hwy.LoadCache(*cachefile)
+				kill(err)
+			}
+			cache.Refresh(refresh...) // also applies to -prev
+			var prev hwy.Graph
+			if *prevfile != "" {
+				file, err := os.Open(*prevfile)
+				kill(err)
+				prev = hwy.ParseGraph(file)
+				file.Close()
+			}
+
+			est, err := hwy.EstimateCost(os.Stdin, prev, cache, prices)
+			kill(err)
+			est.PrettyPrint(os.Stdout)
+			return
+		}
+
+		var geo hwy.Geocoder
+		if *gazetteer != "" {
+			file, err := os.Open(*gazetteer)
+			kill(err)
+			gz, err := hwy.ParseGazetteer(file)
+			kill(err)
+			file.Close()
+			geo = gz
+		} else {
+			gg, err := hwy.NewGoogleGeocoder(readKey(), gmaps.WithRateLimit(*rate))
+			kill(err)
+			geo = gg
+		}
+
+		var dp hwy.DistanceProvider
+		if *estimate {
+			dp = hwy.Estimator{Circuity: *circuity, Speed: *speed}
+		} else {
+			gd, err := hwy.NewGoogleDistances(readKey(), gmaps.WithRateLimit(*rate))
+			kill(err)
+			gd.Retries = *retries
+			dp = gd
+		}
+
+		var cache *hwy.Cache
+		if *cachefile != "" {
+			var err error
+			cache, err = hwy.LoadCache(*cachefile)
+			kill(err)
+			cache.Refresh(refresh...)
+			geo = cache.Geocoder(geo)
+			dp = cache.DistanceProvider(dp)
+		}
+
+		if *prevfile != "" {
+			file, err := os.Open(*prevfile)
+			kill(err)
+			hwy.ConvertRawIncremental(os.Stdin, file, os.Stdout, geo, dp, refresh...)
+			file.Close()
+		} else {
+			hwy.ConvertRaw(os.Stdin, os.Stdout, geo, dp)
+		}
+
+		if cache != nil {
+			cache.PrintStats(os.Stderr)
+			kill(cache.Save(*cachefile))
+		}
+
+	case "repair":
+		// hw repair < raw > repaired raw
+		_, err := hwy.RepairRaw(os.Stdin, os.Stdout)
+		kill(err)
 
 	case "check":
-		fmt.Println("undirected =", hwy.RawIsUndirected(os.Stdin))
+		// hw check < raw
+		raw, err := ioutil.ReadAll(os.Stdin)
+		kill(err)
+		diags := hwy.LintRaw(bytes.NewReader(raw))
+		for _, d := range diags {
+			fmt.Println(d)
+		}
+		fmt.Println("problems =", len(diags))
+		fmt.Println("undirected =", hwy.RawIsUndirected(bytes.NewReader(raw)))
+		fmt.Println("near duplicates =", len(hwy.FindNearDuplicates(bytes.NewReader(raw))))
+	}
+}
+
+// readKey reads the Google Maps API key from the file "KEY".
+func readKey() string {
+	b, err := ioutil.ReadFile("KEY")
+	kill(err)
+	return strings.TrimSpace(string(b))
+}
+
+func kill(err error) {
+	if err != nil {
+		panic(err)
 	}
 }
 
@@ -64,3 +349,87 @@ func argN(n int, def string) string {
 	}
 	return def
 }
+
+// findPlace finds the place in g named by a "CITY NAME,STATE" argument,
+// allowing for a misspelled city name, or exits if there is no such place.
+func findPlace(g hwy.Graph, name string) hwy.Place {
+	parts := strings.Split(name, ",")
+	if len(parts) == 2 {
+		if p, found := g.FindPlaceFuzzy(parts[0], parts[1]); found {
+			if !strings.EqualFold(p.City, strings.TrimSpace(parts[0])) {
+				fmt.Fprintf(os.Stderr, "using %s for %s\n", p.Name(), name)
+			}
+			return p
+		}
+	}
+	fmt.Fprintln(os.Stderr, "place not found:", name)
+	if len(parts) == 2 {
+		printSuggestions(g, parts[0], parts[1])
+	}
+	os.Exit(1)
+	return hwy.Place{}
+}
+
+// printSuggestions prints places in g with names similar to city and state to
+// Stderr.
+func printSuggestions(g hwy.Graph, city, state string) {
+	matches := g.FuzzyFind(city, state, 5)
+	if len(matches) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "did you mean:")
+	for _, m := range matches {
+		fmt.Fprintf(os.Stderr, "\t%s\n", m.Place.Name())
+	}
+}
+
+// routeThrough finds the shortest route (by distance) through the places
+// named by "CITY NAME,STATE" arguments, or exits if there is none.
+func routeThrough(g hwy.Graph, names []string) []hwy.Place {
+	stops := []hwy.Place{}
+	for _, name := range names {
+		stops = append(stops, findPlace(g, name))
+	}
+	it, err := g.RouteVia(hwy.Dist, stops...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	route := []hwy.Place{}
+	for i, leg := range it.Legs {
+		if i > 0 {
+			leg.Places = leg.Places[1:] // don't repeat the stop
+		}
+		route = append(route, leg.Places...)
+	}
+	return route
+}
+
+// readOutline reads a state or country outline file in the JSON or text
+// format made by maps/extract.go, or exits on failure.
+func readOutline(name string) maps.State {
+	file, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+
+	if strings.HasSuffix(name, ".json") {
+		return maps.StateFromJSON(file)
+	}
+	return maps.StateFromText(file)
+}
+
+// stringList is a flag.Value that collects each use of a flag.
+type stringList []string
+
+func (sl *stringList) String() string {
+	return strings.Join(*sl, ";")
+}
+
+func (sl *stringList) Set(value string) error {
+	*sl = append(*sl, value)
+	return nil
+}
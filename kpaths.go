@@ -0,0 +1,121 @@
+package hwy
+
+import (
+	"sort"
+	"strings"
+)
+
+// KShortestPaths finds up to k of the shortest loopless paths between orig
+// and dest using Yen's algorithm. The routes are ranked by their cost
+// according to 'by', so the first route is the same as the one found by
+// ShortestPath. Fewer than k routes are returned if there are not k distinct
+// paths between orig and dest, and nil if there are none at all.
+//
+// https://en.wikipedia.org/wiki/Yen%27s_algorithm
+func (g Graph) KShortestPaths(orig, dest Place, k int, by Accessor) []Route {
+	if k < 1 {
+		return nil
+	}
+	first, _ := g.ShortestPathTo(orig, dest, by).Path(dest)
+	if first == nil {
+		return nil
+	}
+
+	found := []Route{g.route(first, by)}
+	candidates := []Route{}
+	seen := map[string]bool{pathKey(first): true} // found and candidate paths
+
+	for len(found) < k {
+		prev := found[len(found)-1].Places
+
+		// each place in the previous path (except dest) is a "spur" from which
+		// to look for a new deviation from that path.
+		for i := 0; i < len(prev)-1; i++ {
+			spur := prev[i]
+			root := prev[:i+1]
+
+			// remove the edges leaving spur that are used by already found paths
+			// sharing the same root, so that the spur path has to deviate.
+			edges := map[[2]Place]bool{}
+			for _, r := range found {
+				if len(r.Places) > i+1 && samePath(r.Places[:i+1], root) {
+					edges[[2]Place{r.Places[i], r.Places[i+1]}] = true
+				}
+			}
+			// remove the root places (except spur) so the path is loopless.
+			nodes := make(map[Place]bool, i)
+			for _, p := range root[:i] {
+				nodes[p] = true
+			}
+
+			sub := g.without(nodes, edges)
+			spurPath, _ := sub.ShortestPathTo(spur, dest, by).Path(dest)
+			if spurPath == nil {
+				continue
+			}
+
+			path := make([]Place, 0, i+len(spurPath))
+			path = append(path, root[:i]...)
+			path = append(path, spurPath...)
+			if key := pathKey(path); !seen[key] {
+				seen[key] = true
+				candidates = append(candidates, g.route(path, by))
+			}
+		}
+
+		if len(candidates) == 0 {
+			break
+		}
+
+		// move the cheapest candidate to the found routes
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return candidates[i].Cost < candidates[j].Cost
+		})
+		found = append(found, candidates[0])
+		candidates = candidates[1:]
+	}
+
+	return found
+}
+
+// without creates a copy of g that does not contain the given places (and
+// edges to them) or the given directed edges.
+func (g Graph) without(places map[Place]bool, edges map[[2]Place]bool) Graph {
+	sub := make(Graph, len(g))
+	for orig, dests := range g {
+		if places[orig] {
+			continue
+		}
+		em := make(EdgeMap, len(dests))
+		for dest, w := range dests {
+			if places[dest] || edges[[2]Place{orig, dest}] {
+				continue
+			}
+			em[dest] = w
+		}
+		sub[orig] = em
+	}
+	return sub
+}
+
+// samePath reports if a and b have the same places in the same order.
+func samePath(a, b []Place) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pathKey makes a string that uniquely identifies path.
+func pathKey(path []Place) string {
+	names := make([]string, len(path))
+	for i, p := range path {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, majorSep)
+}
@@ -0,0 +1,176 @@
+package hwy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Diagnostic is a problem found in raw data by LintRaw.
+type Diagnostic struct {
+	Line, Column int // 1-based; Column counts bytes
+	Message      string
+
+	// Unusable is true if the entry can't be used as a place at all, because
+	// it is empty or has no valid USPS state code.
+	Unusable bool
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+// uspsStates are the USPS codes of the states, DC, and the territories.
+var uspsStates = map[string]bool{
+	"AL": true, "AK": true, "AZ": true, "AR": true, "CA": true, "CO": true,
+	"CT": true, "DE": true, "FL": true, "GA": true, "HI": true, "ID": true,
+	"IL": true, "IN": true, "IA": true, "KS": true, "KY": true, "LA": true,
+	"ME": true, "MD": true, "MA": true, "MI": true, "MN": true, "MS": true,
+	"MO": true, "MT": true, "NE": true, "NV": true, "NH": true, "NJ": true,
+	"NM": true, "NY": true, "NC": true, "ND": true, "OH": true, "OK": true,
+	"OR": true, "PA": true, "RI": true, "SC": true, "SD": true, "TN": true,
+	"TX": true, "UT": true, "VT": true, "VA": true, "WA": true, "WV": true,
+	"WI": true, "WY": true, "DC": true,
+	"AS": true, "GU": true, "MP": true, "PR": true, "VI": true,
+}
+
+// rawEntry is one place on a line of raw data.
+type rawEntry struct {
+	place  rawPlace
+	line   int
+	column int // of the first non-space character
+}
+
+// LintRaw reads raw graph data from r and reports problems that would make
+// the pipeline produce a wrong graph, each with the line and column where it
+// was found. It checks for:
+//
+//   - missing, invalid, or unknown USPS state codes
+//   - empty vertex or neighbor entries
+//   - places listed as their own neighbor
+//   - neighbors listed more than once for a vertex
+//   - vertices listed on more than one line
+//   - neighbors that are never listed as a vertex
+//
+// Diagnostics are sorted by line then column. A read error is reported as a
+// Diagnostic at the line where it happened.
+func LintRaw(r io.Reader) []Diagnostic {
+	diags := []Diagnostic{}
+	report := func(line, column int, format string, a ...interface{}) {
+		diags = append(diags, Diagnostic{Line: line, Column: column, Message: fmt.Sprintf(format, a...)})
+	}
+	unusable := func(line, column int, format string, a ...interface{}) {
+		report(line, column, format, a...)
+		diags[len(diags)-1].Unusable = true
+	}
+
+	vertices := map[rawPlace]int{} // line the place is a vertex on
+	neighbors := []rawEntry{}
+
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := scanner.Text()
+
+		// ignore comments (#), as parseRawGraph does
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		var vertex rawPlace
+		seen := map[rawPlace]bool{}
+		offset := 0
+		for i, field := range strings.Split(line, majorSep) {
+			column := offset + 1
+			offset += len(field) + len(majorSep)
+
+			trimmed := strings.TrimSpace(field)
+			if trimmed == "" {
+				if i == 0 {
+					unusable(n, column, "empty vertex")
+				} else {
+					report(n, column, "empty neighbor")
+				}
+				continue
+			}
+			column += strings.Index(field, trimmed)
+
+			if !lintRawPlace(trimmed, n, column, unusable) {
+				continue
+			}
+			p := parseRawPlace(trimmed)
+
+			if i == 0 {
+				vertex = p
+				if first, ok := vertices[p]; ok {
+					report(n, column, "%s is already a vertex on line %d", p, first)
+				} else {
+					vertices[p] = n
+				}
+				continue
+			}
+
+			switch {
+			case p == vertex:
+				report(n, column, "%s is its own neighbor", p)
+			case seen[p]:
+				report(n, column, "%s is a neighbor more than once", p)
+			}
+			seen[p] = true
+			neighbors = append(neighbors, rawEntry{place: p, line: n, column: column})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		report(n+1, 1, "%v", err)
+	}
+
+	for _, e := range neighbors {
+		if _, ok := vertices[e.place]; !ok {
+			report(e.line, e.column, "%s is never listed as a vertex", e.place)
+		}
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Column < diags[j].Column
+	})
+	return diags
+}
+
+// lintRawPlace checks that entry, a trimmed raw place at line and column, is
+// a city name followed by a USPS state code. Problems are given to report, and
+// all of them make the entry unusable.
+// Returns false if entry can't be parsed as a place.
+func lintRawPlace(entry string, line, column int, report func(int, int, string, ...interface{})) bool {
+	fields := strings.Fields(entry)
+	if len(fields) < 2 {
+		report(line, column, "%q is missing a city or state", entry)
+		return false
+	}
+
+	state := fields[len(fields)-1]
+	stateColumn := column + strings.LastIndex(entry, state)
+	switch {
+	case len(state) != 2 || !isLetters(state):
+		report(line, stateColumn, "invalid state code %q", state)
+		return false
+	case !uspsStates[strings.ToUpper(state)]:
+		report(line, stateColumn, "unknown state code %q", state)
+	}
+	return true
+}
+
+// isLetters is true if s only has ASCII letters.
+func isLetters(s string) bool {
+	for _, c := range s {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
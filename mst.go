@@ -0,0 +1,211 @@
+package hwy
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// edge is an undirected connection between two places, with a sorted
+// before b according to ByState.
+type edge struct {
+	a, b Place
+}
+
+// lessByState reports if p sorts before q according to ByState.
+func lessByState(p, q Place) bool {
+	return ByState{p, q}.Less(0, 1)
+}
+
+// undirectedEdges gives each connection in g once, no matter if it appears in
+// one or both directions. The edges are sorted by ByState.
+func (g Graph) undirectedEdges() []edge {
+	seen := map[edge]bool{}
+	edges := []edge{}
+	for orig, dests := range g {
+		for dest := range dests {
+			e := edge{orig, dest}
+			if lessByState(dest, orig) {
+				e = edge{dest, orig}
+			}
+			if !seen[e] {
+				seen[e] = true
+				edges = append(edges, e)
+			}
+		}
+	}
+
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].a != edges[j].a {
+			return lessByState(edges[i].a, edges[j].a)
+		}
+		return lessByState(edges[i].b, edges[j].b)
+	})
+	return edges
+}
+
+// undirectedCost gives the cheaper of the two directions of the connection
+// between a and b.
+func (g Graph) undirectedCost(a, b Place, by Accessor) float64 {
+	w, ok := g.Edge(a, b)
+	rw, rok := g.Edge(b, a)
+	switch {
+	case ok && rok:
+		return Min(by(w), by(rw))
+	case ok:
+		return by(w)
+	default:
+		return by(rw)
+	}
+}
+
+// addTreeEdge adds the connection between a and b to tree in both directions,
+// using the Weights from g.
+func (g Graph) addTreeEdge(tree Graph, a, b Place) {
+	if w, ok := g.Edge(a, b); ok {
+		tree[a][b] = w
+	}
+	if w, ok := g.Edge(b, a); ok {
+		tree[b][a] = w
+	}
+}
+
+// emptyCopy creates a graph with the same places as g but no edges.
+func (g Graph) emptyCopy() Graph {
+	tree := make(Graph, len(g))
+	for p := range g {
+		tree[p] = EdgeMap{}
+	}
+	return tree
+}
+
+// MinimumSpanningTree finds the minimum spanning tree of g using Kruskal's
+// algorithm. See Kruskal.
+func (g Graph) MinimumSpanningTree(by Accessor) (tree Graph, total float64) {
+	return g.Kruskal(by)
+}
+
+// Kruskal finds the minimum spanning tree of g using Kruskal's algorithm.
+// The graph is treated as undirected, with the cost of each connection being
+// the cheaper of its two directions. The resulting tree contains every place
+// in g and only the edges of the tree, in both directions, with their
+// original Weights. total is the sum of the costs of the tree's connections.
+// If g is not connected, the result is a minimum spanning forest.
+//
+// https://en.wikipedia.org/wiki/Kruskal%27s_algorithm
+func (g Graph) Kruskal(by Accessor) (tree Graph, total float64) {
+	edges := g.undirectedEdges()
+	costs := make(map[edge]float64, len(edges))
+	for _, e := range edges {
+		costs[e] = g.undirectedCost(e.a, e.b, by)
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		return costs[edges[i]] < costs[edges[j]]
+	})
+
+	tree = g.emptyCopy()
+	sets := newDisjointSet(g.Places())
+	for _, e := range edges {
+		// an edge joining two places already in the same tree makes a cycle
+		if sets.union(e.a, e.b) {
+			g.addTreeEdge(tree, e.a, e.b)
+			total += costs[e]
+		}
+	}
+
+	return tree, total
+}
+
+// Prim finds the minimum spanning tree of g using Prim's algorithm. The
+// result is the same as Kruskal (aside from choices between connections of
+// equal cost).
+//
+// https://en.wikipedia.org/wiki/Prim%27s_algorithm
+func (g Graph) Prim(by Accessor) (tree Graph, total float64) {
+	tree = g.emptyCopy()
+	intree := make(map[Place]bool, len(g))
+	parent := make(map[Place]Place, len(g))
+	cheapest := make(map[Place]float64, len(g)) // cost of connection to parent
+
+	// start a new tree from each place not already in one, which makes a
+	// forest if g is not connected.
+	roots := g.Places()
+	sort.Sort(ByState(roots))
+	for _, root := range roots {
+		if intree[root] {
+			continue
+		}
+
+		pq := &placeQueue{{place: root, prio: 0}}
+		for pq.Len() > 0 {
+			item := heap.Pop(pq).(pqItem)
+			current := item.place
+			if intree[current] {
+				continue // stale entry
+			}
+			intree[current] = true
+			if current != root {
+				g.addTreeEdge(tree, parent[current], current)
+				total += item.prio
+			}
+
+			// the queue holds the cheapest known connection of each place
+			// to the tree so far.
+			for n := range g[current] {
+				if intree[n] {
+					continue
+				}
+				cost := g.undirectedCost(current, n, by)
+				if c, ok := cheapest[n]; !ok || cost < c {
+					cheapest[n] = cost
+					parent[n] = current
+					heap.Push(pq, pqItem{place: n, prio: cost})
+				}
+			}
+		}
+	}
+
+	return tree, total
+}
+
+// disjointSet is a union-find structure for places.
+type disjointSet struct {
+	parent map[Place]Place
+	rank   map[Place]int
+}
+
+func newDisjointSet(places []Place) *disjointSet {
+	s := &disjointSet{
+		parent: make(map[Place]Place, len(places)),
+		rank:   make(map[Place]int, len(places)),
+	}
+	for _, p := range places {
+		s.parent[p] = p
+	}
+	return s
+}
+
+// find gives the representative of the set containing p.
+func (s *disjointSet) find(p Place) Place {
+	for s.parent[p] != p {
+		s.parent[p] = s.parent[s.parent[p]] // path halving
+		p = s.parent[p]
+	}
+	return p
+}
+
+// union merges the sets containing p and q, returning false if they were
+// already the same set.
+func (s *disjointSet) union(p, q Place) bool {
+	p, q = s.find(p), s.find(q)
+	if p == q {
+		return false
+	}
+	if s.rank[p] < s.rank[q] {
+		p, q = q, p
+	}
+	s.parent[q] = p
+	if s.rank[p] == s.rank[q] {
+		s.rank[p]++
+	}
+	return true
+}
@@ -2,15 +2,16 @@ package hwy
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"sort"
 	"strings"
 	"time"
-
-	"googlemaps.github.io/maps"
 )
 
 // These types and functions process a "raw" file (just place names)
@@ -32,8 +33,14 @@ func (p rawPlace) String() string {
 type rawGraph map[rawPlace][]rawPlace
 
 // parseRawPlace parses raw format `city name state` into a place. Raw format
-// always has last 2 chars as the state abbreviation.
+// always has last 2 chars (ignoring surrounding space) as the state
+// abbreviation. See LintRaw for checking that the format is correct.
 func parseRawPlace(raw string) (p rawPlace) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) < 2 {
+		p.city = raw
+		return
+	}
 	split := len(raw) - 2
 	p.city = strings.Title(strings.TrimSpace(raw[:split]))
 	p.state = strings.ToUpper(strings.TrimSpace(raw[split:]))
@@ -49,10 +56,21 @@ func rawKeys(rg rawGraph) []rawPlace {
 }
 
 func parseRawGraph(r io.Reader) rawGraph {
+	return scanRawGraph(r, func(line int, first rawPlace, neighbors []rawPlace) {
+		fmt.Fprintln(os.Stderr, first, "already was in raw graph.")
+	})
+}
+
+// scanRawGraph parses raw data from r like parseRawGraph, but calls dup with
+// the line number, vertex, and neighbors of each line for a vertex that was
+// already on an earlier line, instead of ignoring the line.
+func scanRawGraph(r io.Reader, dup func(line int, first rawPlace, neighbors []rawPlace)) rawGraph {
 	g := rawGraph{}
 
 	scanner := bufio.NewScanner(r)
+	lineno := 0
 	for scanner.Scan() {
+		lineno++
 		raw := scanner.Text()
 
 		// ignore comments (#)
@@ -64,12 +82,15 @@ func parseRawGraph(r io.Reader) rawGraph {
 		first := parseRawPlace(rawcities[0])
 		neighbors := []rawPlace{}
 		for _, c := range rawcities[1:] {
+			if strings.TrimSpace(c) == "" {
+				continue
+			}
 			neighbors = append(neighbors, parseRawPlace(c))
 		}
 		if _, ok := g[first]; !ok {
 			g[first] = neighbors
 		} else {
-			fmt.Fprintln(os.Stderr, first, "already was in raw graph.")
+			dup(lineno, first, neighbors)
 		}
 	}
 	if err := scanner.Err(); err != nil {
@@ -100,37 +121,63 @@ func rawIsUndirected(g rawGraph) (undirected bool) {
 	return
 }
 
-func requestLocsFromGoogle(raw []rawPlace, apikey string) []Place {
-	places := make([]Place, 0, len(raw))
+// warnNearDuplicates prints the places in rg that may be two spellings of the
+// same place to Stderr, so they can be fixed before paying to geocode both.
+func warnNearDuplicates(rg rawGraph) {
+	for _, d := range nearDuplicates(rg) {
+		fmt.Fprintln(os.Stderr, "warning:", d)
+	}
+}
 
-	client, err := maps.NewClient(maps.WithAPIKey(apikey))
-	if err != nil {
-		panic(err)
+// errUndirected is returned when raw data has edges in only one direction.
+var errUndirected = errors.New("raw data is not undirected")
+
+// weigh uses dp to set the Weights of the edges in g, and prints any error to
+// Stderr. If the error is DistanceErrors, the other edges were weighed and
+// the failed ones should be removed with removeFailedEdges.
+func weigh(ctx context.Context, dp DistanceProvider, g Graph) error {
+	err := dp.Weigh(ctx, g)
+	var derrs DistanceErrors
+	switch {
+	case err == nil:
+	case errors.As(err, &derrs):
+		for _, e := range derrs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		fmt.Fprintf(os.Stderr, "%d edges could not be weighed and were left out.\n", len(derrs))
+	default:
+		fmt.Fprintln(os.Stderr, err)
+	}
+	return err
+}
+
+// removeFailedEdges deletes the edges in err, if it is DistanceErrors, from g
+// in both directions, so that g has no edges without Weights and stays
+// undirected. Since they are left out of the graph, UpdateRaw will request
+// them again.
+func removeFailedEdges(g Graph, err error) {
+	var derrs DistanceErrors
+	if !errors.As(err, &derrs) {
+		return
 	}
+	for _, e := range derrs {
+		delete(g[e.Orig], e.Dest)
+		delete(g[e.Dest], e.Orig)
+	}
+}
+
+// requestLocs uses geo to get the location of each place in raw. Places that
+// can't be found are printed to Stderr and left out of the result.
+func requestLocs(ctx context.Context, raw []rawPlace, geo Geocoder) []Place {
+	places := make([]Place, 0, len(raw))
 
-	ctx := context.Background()
 	for _, p := range raw {
-		req := &maps.GeocodingRequest{Address: fmt.Sprintf("%s, %s", p.city, p.state)}
-		results, err := client.Geocode(ctx, req)
+		place, err := geo.Geocode(ctx, p.city, p.state)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, p, err)
-		}
-
-		if len(results) < 1 {
-			fmt.Fprintln(os.Stderr, p, "no results")
 			continue
 		}
-
-		places = append(places, Place{
-			City:      p.city,
-			State:     p.state,
-			Latitude:  results[0].Geometry.Location.Lat,
-			Longitude: results[0].Geometry.Location.Lng,
-		})
-
-		if len(results) > 1 {
-			fmt.Fprintln(os.Stderr, p, "more than 1 result?", results)
-		}
+		places = append(places, place)
 	}
 
 	return places
@@ -141,7 +188,7 @@ func convertRawGraphToGraph(raw rawGraph, places []Place) Graph {
 
 	// use 'find' to easily get Place from rawPlace
 	sort.Sort(ByCity(places))
-	find := func(rp rawPlace) Place {
+	find := func(rp rawPlace) (Place, bool) {
 		// linear search
 		var i int
 		for ; i < len(places); i++ {
@@ -154,104 +201,203 @@ func convertRawGraphToGraph(raw rawGraph, places []Place) Graph {
 		// 	return strings.Compare(rp.city+","+rp.state, places[i].Name()) <= 0
 		// })
 		if i >= len(places) {
-			fmt.Fprintln(os.Stderr, i, rp, places)
+			// no location for this place (eg the Geocoder couldn't find it)
+			fmt.Fprintln(os.Stderr, rp, "has no location and was left out.")
+			return Place{}, false
 		}
-		return places[i]
+		return places[i], true
 	}
 
 	for k, v := range raw {
+		orig, ok := find(k)
+		if !ok {
+			continue
+		}
 		em := make(EdgeMap, len(v)) // create an empty edge map
 		for _, p := range v {
 			// convert each rawPlace to Place and insert into EdgeMap
-			em[find(p)] = Weight{} // zero-val Weight
+			if dest, ok := find(p); ok {
+				em[dest] = Weight{} // zero-val Weight
+			}
 		}
-		g[find(k)] = em // add the origin and edges
+		g[orig] = em // add the origin and edges
 	}
 
 	return g
 }
 
-// modifies graph in-place, returns same one
-func requestDistFromGoogle(g Graph, apikey string) Graph {
-	client, err := maps.NewClient(maps.WithAPIKey(apikey))
-	if err != nil {
-		panic(err)
-	}
+// FullyProcessRaw reads and parses the "raw hand-entered data" format for
+// highway connections, then uses geo to get place locations (lat, lon) and dp
+// to get travel times and distances (by car) between connected places. Checks
+// graph for undirectedness before performing requests and returns nil (as well
+// as printing errors to Stderr) if the graph has directed edges. Edges that dp
+// can't weigh are printed to Stderr and left out of the graph.
+//
+// NOTE: the Google Maps API requires a key and may also incure useage charges.
+// See GoogleGeocoder and GoogleDistances, or Gazetteer and Estimator for
+// offline alternatives.
+func FullyProcessRaw(r io.Reader, geo Geocoder, dp DistanceProvider) Graph {
+	g, _ := FullyProcessRawContext(context.Background(), r, geo, dp) // errors were printed
+	return g
+}
 
-	ctx := context.Background()
-	for orig, dests := range g {
+// FullyProcessRawContext is FullyProcessRaw with ctx given to geo and dp, so
+// that the requests can be cancelled. If some edges couldn't be weighed, they
+// are left out of the graph in both directions and the error is
+// DistanceErrors. Any other error means there is no graph.
+func FullyProcessRawContext(ctx context.Context, r io.Reader, geo Geocoder, dp DistanceProvider) (Graph, error) {
+	fmt.Fprintln(os.Stderr, "parsing raw data from input Reader.")
+	rg := parseRawGraph(r)
 
-		// prepare request
-		req := &maps.DistanceMatrixRequest{Origins: []string{orig.Name()}}
+	if !rawIsUndirected(rg) {
+		return nil, errUndirected
+	}
+	warnNearDuplicates(rg)
 
-		// need to keep a list of destinations in the same order as was used
-		// to prepare the request, so that the response data can be retrieved
-		// in the correct order.
-		destlist := make([]Place, 0, len(dests))
-		for d := range dests {
-			destlist = append(destlist, d)
-			req.Destinations = append(req.Destinations, d.Name())
-		}
+	fmt.Fprintf(os.Stderr, "requesting locations. %d Geocoder calls.\n", len(rg))
+	places := requestLocs(ctx, rawKeys(rg), geo)
 
-		// make request
-		resp, err := client.DistanceMatrix(ctx, req)
-		if err != nil {
-			fmt.Fprintln(os.Stderr, orig, err)
-			continue
-		}
-		if len(resp.Rows) == 0 {
-			fmt.Fprintln(os.Stderr, orig, "no rows")
-			continue
-		}
-		if len(resp.Rows) > 1 {
-			fmt.Fprintln(os.Stderr, orig, "more than one row")
-		}
+	fmt.Fprintf(os.Stderr, "got back %d places.\n", len(places))
+	fmt.Fprintln(os.Stderr, "adding locations to graph.")
+	g := convertRawGraphToGraph(rg, places)
 
-		// add the Weight data to Graph
-		for i, d := range destlist {
-			elem := resp.Rows[0].Elements[i]
-			g[orig][d] = Weight{Distance: float64(elem.Distance.Meters), TravelTime: elem.Duration}
-		}
+	fmt.Fprintf(os.Stderr, "requesting distances for %d places.\n", len(g))
+	err := weigh(ctx, dp, g)
+	var derrs DistanceErrors
+	if err != nil && !errors.As(err, &derrs) {
+		return nil, err
 	}
+	removeFailedEdges(g, err)
+
+	return g, err
+}
 
+// UpdateRaw is like FullyProcessRaw, but reuses the locations and Weights in
+// prev, a previous result of the pipeline, for the places and edges that are
+// still in the raw data. Only the new places are given to geo and only the new
+// edges to dp. The places and edges that were added or removed relative to
+// prev are printed to Stderr. The places named "city,state" (any case) in
+// refresh, and the edges to and from them, are requested again instead of
+// being taken from prev, as for Cache.Refresh.
+func UpdateRaw(r io.Reader, prev Graph, geo Geocoder, dp DistanceProvider, refresh ...string) Graph {
+	g, _ := UpdateRawContext(context.Background(), r, prev, geo, dp, refresh...) // errors were printed
 	return g
 }
 
-// FullyProcessRaw reads and parses the "raw hand-entered data" format for
-// highway connections, then uses Google APIs to get place locations (lat, lon)
-// and travel times and distances (by car) between connected places. Checks
-// graph for undirectedness before performing requests and returns nil (as well
-// as printing errors to Stderr) if the graph has directed edges.
-//
-// NOTE: the Google Maps API requires a key and may also incure useage charges.
-func FullyProcessRaw(r io.Reader, apikey string) Graph {
+// UpdateRawContext is UpdateRaw with ctx given to geo and dp, so that the
+// requests can be cancelled. Errors are as for FullyProcessRawContext.
+func UpdateRawContext(ctx context.Context, r io.Reader, prev Graph, geo Geocoder, dp DistanceProvider, refresh ...string) (Graph, error) {
 	fmt.Fprintln(os.Stderr, "parsing raw data from input Reader.")
 	rg := parseRawGraph(r)
 
 	if !rawIsUndirected(rg) {
-		return nil
+		return nil, errUndirected
 	}
+	warnNearDuplicates(rg)
 
-	fmt.Fprintf(os.Stderr, "requesting locations from Google. %d Geocoding API calls.\n", len(rg))
-	places := requestLocsFromGoogle(rawKeys(rg), apikey) // makes calls to Google Maps Geocoding API
+	if len(refresh) > 0 {
+		fmt.Fprintln(os.Stderr, "not reusing:", strings.Join(refresh, "; "))
+		prev = withoutRefreshed(prev, refreshKeys(refresh))
+	}
+
+	// find places in prev by the same name as the raw places
+	prevPlaces := make(map[string]Place, len(prev))
+	for p := range prev {
+		prevPlaces[placeKey(p.City, p.State)] = p
+	}
+
+	places := make([]Place, 0, len(rg))
+	added := []rawPlace{}
+	for _, rp := range rawKeys(rg) {
+		key := placeKey(rp.city, rp.state)
+		if p, ok := prevPlaces[key]; ok {
+			// use the raw name, which convertRawGraphToGraph matches exactly
+			p.City, p.State = rp.city, rp.state
+			places = append(places, p)
+			delete(prevPlaces, key) // whatever is left over was removed
+		} else {
+			added = append(added, rp)
+			fmt.Fprintln(os.Stderr, "new place:", rp)
+		}
+	}
+	for _, p := range prevPlaces {
+		fmt.Fprintln(os.Stderr, "removed place:", p.Name())
+	}
+
+	fmt.Fprintf(os.Stderr, "requesting locations. %d Geocoder calls.\n", len(added))
+	places = append(places, requestLocs(ctx, added, geo)...)
 
-	fmt.Fprintf(os.Stderr, "got back %d places.\n", len(places))
 	fmt.Fprintln(os.Stderr, "adding locations to graph.")
 	g := convertRawGraphToGraph(rg, places)
 
-	fmt.Fprintf(os.Stderr, "requesting distances from Google. %d Distance Matrix API calls.\n", len(g))
-	g = requestDistFromGoogle(g, apikey) // makes calls to Google Maps Distance Matrix API
+	// reuse the Weights of edges that were in prev, and collect the rest
+	prevWeights := map[string]Weight{}
+	for orig, dests := range prev {
+		for dest, w := range dests {
+			prevWeights[pairKey(orig, dest)] = w
+		}
+	}
+	missing := Graph{}
+	for orig, dests := range g {
+		for dest := range dests {
+			key := pairKey(orig, dest)
+			if w, ok := prevWeights[key]; ok {
+				dests[dest] = w
+				delete(prevWeights, key) // whatever is left over was removed
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "new edge: %s -> %s\n", orig.Name(), dest.Name())
+			if missing[orig] == nil {
+				missing[orig] = EdgeMap{}
+			}
+			missing[orig][dest] = Weight{}
+		}
+	}
+	for key := range prevWeights {
+		fmt.Fprintln(os.Stderr, "removed edge:", strings.Replace(key, majorSep, " -> ", 1))
+	}
 
-	return g
+	fmt.Fprintf(os.Stderr, "requesting distances for %d places.\n", len(missing))
+	if len(missing) == 0 {
+		return g, nil
+	}
+	err := weigh(ctx, dp, missing)
+	var derrs DistanceErrors
+	if err != nil && !errors.As(err, &derrs) {
+		return nil, err
+	}
+	for orig, dests := range missing {
+		for dest, w := range dests {
+			g[orig][dest] = w
+		}
+	}
+	removeFailedEdges(g, err)
+
+	return g, err
 }
 
 // ConvertRaw does the same steps as FullyProcessRaw(), but writes the resulting
 // graph to w instead of returning it.
-func ConvertRaw(r io.Reader, w io.Writer, apikey string) {
+func ConvertRaw(r io.Reader, w io.Writer, geo Geocoder, dp DistanceProvider) {
 	start := time.Now()
 	fmt.Fprintln(os.Stderr, "starting.")
 
-	g := FullyProcessRaw(r, apikey)
+	g := FullyProcessRaw(r, geo, dp)
+
+	fmt.Fprintln(os.Stderr, "writing fully processed graph to output Writer.")
+	w.Write([]byte(g.String()))
+
+	fmt.Fprintf(os.Stderr, "done. took %s.\n", time.Since(start))
+}
+
+// ConvertRawIncremental does the same steps as UpdateRaw(), with the previous
+// graph parsed from prev, and writes the resulting graph to w.
+func ConvertRawIncremental(r io.Reader, prev io.Reader, w io.Writer, geo Geocoder, dp DistanceProvider, refresh ...string) {
+	start := time.Now()
+	fmt.Fprintln(os.Stderr, "starting.")
+
+	fmt.Fprintln(os.Stderr, "parsing previous graph.")
+	g := UpdateRaw(r, ParseGraph(prev), geo, dp, refresh...)
 
 	fmt.Fprintln(os.Stderr, "writing fully processed graph to output Writer.")
 	w.Write([]byte(g.String()))
@@ -266,3 +412,161 @@ func RawIsUndirected(r io.Reader) bool {
 	rg := parseRawGraph(r)
 	return rawIsUndirected(rg)
 }
+
+// RawFix is an adjacency that RepairRaw added to (or removed from) the raw
+// data, so that From lists (or doesn't list) To as a neighbor.
+type RawFix struct {
+	From, To string // "City,ST"
+	Removed  bool
+	Reason   string
+}
+
+func (f RawFix) String() string {
+	if f.Removed {
+		return fmt.Sprintf("removed %s -> %s (%s)", f.From, f.To, f.Reason)
+	}
+	return fmt.Sprintf("added %s -> %s (%s)", f.From, f.To, f.Reason)
+}
+
+// RepairRaw parses raw graph data from r and adds the missing reverse
+// adjacencies so the raw graph is undirected. Places that were only listed as
+// neighbors become vertices, the neighbors on later lines for a vertex are
+// merged into its first line, and places listed as their own neighbor are
+// removed from their line. Each fix is printed to Stderr and returned. If w is
+// not nil, the repaired raw data is written to it in normalized form: one line
+// per vertex, grouped under a comment for each state, with vertices and
+// neighbors sorted by state then city and duplicate neighbors removed.
+//
+// The raw data is checked with LintRaw first. If any entry can't be used as a
+// place (see Diagnostic.Unusable), nothing is repaired and the error lists
+// those entries, since they can't be fixed automatically.
+func RepairRaw(r io.Reader, w io.Writer) ([]RawFix, error) {
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	bad := []string{}
+	for _, d := range LintRaw(bytes.NewReader(raw)) {
+		if d.Unusable {
+			bad = append(bad, d.String())
+		}
+	}
+	if len(bad) > 0 {
+		return nil, fmt.Errorf("%d unusable entries:\n%s", len(bad), strings.Join(bad, "\n"))
+	}
+	r = bytes.NewReader(raw)
+
+	type dupLine struct {
+		line      int
+		first     rawPlace
+		neighbors []rawPlace
+	}
+	dups := []dupLine{}
+	rg := scanRawGraph(r, func(line int, first rawPlace, neighbors []rawPlace) {
+		dups = append(dups, dupLine{line, first, neighbors})
+	})
+
+	fixes := []RawFix{}
+	for _, d := range dups {
+		for _, n := range d.neighbors {
+			if !rawHasNeighbor(rg, d.first, n) {
+				rg[d.first] = append(rg[d.first], n)
+				fixes = append(fixes, RawFix{From: d.first.String(), To: n.String(),
+					Reason: fmt.Sprintf("merged from line %d", d.line)})
+			}
+		}
+	}
+	fixes = append(fixes, repairRawGraph(rg)...)
+	for _, f := range fixes {
+		fmt.Fprintln(os.Stderr, f)
+	}
+	if w == nil {
+		return fixes, nil
+	}
+	return fixes, writeRawGraph(w, rg)
+}
+
+// lessRaw orders raw places by state then city.
+func lessRaw(a, b rawPlace) bool {
+	if a.state != b.state {
+		return a.state < b.state
+	}
+	return a.city < b.city
+}
+
+// sortedRawKeys gives the vertices of rg sorted by lessRaw.
+func sortedRawKeys(rg rawGraph) []rawPlace {
+	keys := rawKeys(rg)
+	sort.Slice(keys, func(i, j int) bool { return lessRaw(keys[i], keys[j]) })
+	return keys
+}
+
+// rawHasNeighbor is true if rg lists b as a neighbor of a.
+func rawHasNeighbor(rg rawGraph, a, b rawPlace) bool {
+	for _, n := range rg[a] {
+		if n == b {
+			return true
+		}
+	}
+	return false
+}
+
+// repairRawGraph removes self-loops from rg, and adds b -> a wherever a -> b
+// exists without it.
+func repairRawGraph(rg rawGraph) (fixes []RawFix) {
+	for _, a := range sortedRawKeys(rg) {
+		kept := rg[a][:0]
+		for _, b := range rg[a] {
+			if a == b {
+				fixes = append(fixes, RawFix{From: a.String(), To: b.String(), Removed: true, Reason: "self-loop"})
+				continue
+			}
+			kept = append(kept, b)
+		}
+		rg[a] = kept
+	}
+
+	for _, a := range sortedRawKeys(rg) {
+		for _, b := range rg[a] {
+			if rawHasNeighbor(rg, b, a) {
+				continue
+			}
+			rg[b] = append(rg[b], a)
+			fixes = append(fixes, RawFix{From: b.String(), To: a.String(),
+				Reason: fmt.Sprintf("reverse of %s -> %s", a, b)})
+		}
+	}
+	return
+}
+
+// writeRawGraph writes rg to w in normalized raw format. Names keep the case
+// they were parsed with, so they parse back to the same places.
+func writeRawGraph(w io.Writer, rg rawGraph) error {
+	format := func(p rawPlace) string {
+		return p.city + " " + p.state
+	}
+
+	ew := &errWriter{w: w}
+	state := ""
+	for i, p := range sortedRawKeys(rg) {
+		if p.state != state {
+			if i > 0 {
+				ew.printf("\n")
+			}
+			ew.printf("# %s\n", p.state)
+			state = p.state
+		}
+
+		neighbors := append([]rawPlace{}, rg[p]...)
+		sort.Slice(neighbors, func(i, j int) bool { return lessRaw(neighbors[i], neighbors[j]) })
+		line := []string{format(p)}
+		for j, n := range neighbors {
+			if j > 0 && n == neighbors[j-1] {
+				continue
+			}
+			line = append(line, format(n))
+		}
+		ew.printf("%s\n", strings.Join(line, majorSep+" "))
+	}
+	return ew.err
+}
@@ -0,0 +1,189 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// layer collects the shapes of one color so that they can be rasterized
+// together.
+type layer struct {
+	z     *vector.Rasterizer
+	empty bool
+}
+
+func newLayer(width, height int) *layer {
+	return &layer{z: vector.NewRasterizer(width, height), empty: true}
+}
+
+// polygon adds a closed polygon to the layer. All polygons are added with the
+// same winding so that overlapping shapes don't cancel each other out.
+func (l *layer) polygon(pts [][2]float64) {
+	if len(pts) < 3 {
+		return
+	}
+	// shoelace formula for (twice) the signed area
+	var area float64
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		area += pts[i][0]*pts[j][1] - pts[j][0]*pts[i][1]
+	}
+	if area < 0 {
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+
+	l.z.MoveTo(float32(pts[0][0]), float32(pts[0][1]))
+	for _, pt := range pts[1:] {
+		l.z.LineTo(float32(pt[0]), float32(pt[1]))
+	}
+	l.z.ClosePath()
+	l.empty = false
+}
+
+// line adds a line of the given thickness from (x1,y1) to (x2,y2).
+func (l *layer) line(x1, y1, x2, y2, thickness float64) {
+	dx, dy := x2-x1, y2-y1
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return
+	}
+	// offset perpendicular to the line by half the thickness
+	ox, oy := -dy/length*thickness/2, dx/length*thickness/2
+	l.polygon([][2]float64{
+		{x1 + ox, y1 + oy},
+		{x2 + ox, y2 + oy},
+		{x2 - ox, y2 - oy},
+		{x1 - ox, y1 - oy},
+	})
+}
+
+// circle adds a filled circle centered at (x,y).
+func (l *layer) circle(x, y, radius float64) {
+	n := int(math.Max(8, math.Min(64, radius*2))) // segments
+	pts := make([][2]float64, n)
+	for i := range pts {
+		a := 2 * math.Pi * float64(i) / float64(n)
+		pts[i] = [2]float64{x + radius*math.Cos(a), y + radius*math.Sin(a)}
+	}
+	l.polygon(pts)
+}
+
+// draw paints the layer onto dst in color c.
+func (l *layer) draw(dst draw.Image, c color.Color) {
+	if !l.empty {
+		l.z.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{})
+	}
+}
+
+// Image draws the map to a new image of Width by Height pixels.
+func (m *Map) Image() *image.RGBA {
+	s := m.scale()
+	st := m.Style
+	img := image.NewRGBA(image.Rect(0, 0, m.Width, m.Height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(st.Background), image.Point{}, draw.Src)
+
+	if m.Grid {
+		grid := newLayer(m.Width, m.Height)
+		for _, l := range gridLines() {
+			x1, y1 := m.project(l[0][0], l[0][1])
+			x2, y2 := m.project(l[1][0], l[1][1])
+			grid.line(x1, y1, x2, y2, st.GridThickness*s)
+		}
+		grid.draw(img, st.Grid)
+	}
+
+	if len(m.Outlines) > 0 {
+		outlines := newLayer(m.Width, m.Height)
+		for _, state := range m.Outlines {
+			for _, poly := range state.Polygons {
+				m.polyline(outlines, outlinePoints(poly), true, st.OutlineThickness*s)
+			}
+		}
+		outlines.draw(img, st.Outline)
+	}
+
+	if m.Graph != nil {
+		edges := newLayer(m.Width, m.Height)
+		for _, e := range m.edges() {
+			x1, y1 := m.project(e[0].Longitude, e[0].Latitude)
+			x2, y2 := m.project(e[1].Longitude, e[1].Latitude)
+			edges.line(x1, y1, x2, y2, st.EdgeThickness*s)
+		}
+		edges.draw(img, st.Edge)
+
+		vertices := newLayer(m.Width, m.Height)
+		for _, p := range m.places() {
+			x, y := m.project(p.Longitude, p.Latitude)
+			vertices.circle(x, y, m.vertexRadius(p.Latitude))
+		}
+		vertices.draw(img, st.Vertex)
+
+		if m.Labels {
+			m.drawLabels(img)
+		}
+	}
+
+	if len(m.Route) > 0 {
+		route := newLayer(m.Width, m.Height)
+		pts := make([][2]float64, len(m.Route))
+		for i, p := range m.Route {
+			pts[i] = [2]float64{p.Longitude, p.Latitude}
+			x, y := m.project(p.Longitude, p.Latitude)
+			route.circle(x, y, m.vertexRadius(p.Latitude))
+		}
+		m.polyline(route, pts, false, st.EdgeThickness*s)
+		route.draw(img, st.Route)
+	}
+
+	return img
+}
+
+// polyline adds lines between each of pts (lon, lat) to l, and back to the
+// first point if closed.
+func (m *Map) polyline(l *layer, pts [][2]float64, closed bool, thickness float64) {
+	n := len(pts) - 1
+	if closed {
+		n = len(pts)
+	}
+	for i := 0; i < n; i++ {
+		j := (i + 1) % len(pts)
+		x1, y1 := m.project(pts[i][0], pts[i][1])
+		x2, y2 := m.project(pts[j][0], pts[j][1])
+		l.line(x1, y1, x2, y2, thickness)
+	}
+}
+
+// drawLabels writes the name of each place next to it.
+func (m *Map) drawLabels(img *image.RGBA) {
+	f, err := truetype.Parse(goregular.TTF)
+	if err != nil {
+		panic(err) // the embedded font is known to be good
+	}
+	face := truetype.NewFace(f, &truetype.Options{Size: m.Style.LabelSize * m.scale()})
+	defer face.Close()
+
+	d := font.Drawer{Dst: img, Src: image.NewUniform(m.Style.Label), Face: face}
+	for _, p := range m.places() {
+		x, y := m.project(p.Longitude, p.Latitude)
+		r := m.vertexRadius(p.Latitude)
+		d.Dot = fixed.P(int(x+r), int(y-r))
+		d.DrawString(p.Name())
+	}
+}
+
+// WritePNG draws the map to w as a PNG image.
+func (m *Map) WritePNG(w io.Writer) error {
+	return png.Encode(w, m.Image())
+}
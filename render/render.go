@@ -0,0 +1,202 @@
+// Package render draws maps of the highway graph to images without needing a
+// display, using the same conventions and styling as the viewer: longitude is
+// the x axis, latitude is the y axis, and both are scaled equally.
+package render
+
+import (
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/quillaja/hwy"
+	"github.com/quillaja/hwy/maps"
+	"golang.org/x/image/colornames"
+)
+
+// Bounds is the area of the map to draw, in degrees.
+type Bounds struct {
+	MinLon, MinLat float64
+	MaxLon, MaxLat float64
+}
+
+// USBounds covers the contiguous United States.
+var USBounds = Bounds{MinLon: -125, MinLat: 24, MaxLon: -66, MaxLat: 50}
+
+// GraphBounds gives the smallest Bounds containing every place in g, plus
+// margin degrees on each side.
+func GraphBounds(g hwy.Graph, margin float64) Bounds {
+	b := Bounds{
+		MinLon: math.Inf(1), MinLat: math.Inf(1),
+		MaxLon: math.Inf(-1), MaxLat: math.Inf(-1),
+	}
+	for p := range g {
+		b.MinLon = math.Min(b.MinLon, p.Longitude)
+		b.MinLat = math.Min(b.MinLat, p.Latitude)
+		b.MaxLon = math.Max(b.MaxLon, p.Longitude)
+		b.MaxLat = math.Max(b.MaxLat, p.Latitude)
+	}
+	if len(g) == 0 {
+		return USBounds
+	}
+	b.MinLon -= margin
+	b.MinLat -= margin
+	b.MaxLon += margin
+	b.MaxLat += margin
+	return b
+}
+
+// Style holds the colors and sizes of the things drawn on a map. Sizes are in
+// degrees, so they scale with the map.
+type Style struct {
+	Background color.Color
+	Grid       color.Color
+	Outline    color.Color
+	Edge       color.Color
+	Vertex     color.Color
+	Label      color.Color
+	Route      color.Color
+
+	GridThickness    float64
+	OutlineThickness float64
+	EdgeThickness    float64
+
+	// VertexRadius is in meters, so that vertices are the same size on the
+	// ground no matter their latitude.
+	VertexRadius float64
+
+	// LabelSize is the height of the label text.
+	LabelSize float64
+}
+
+// DefaultStyle matches the viewer.
+var DefaultStyle = Style{
+	Background: colornames.White,
+	Grid:       colornames.Gray,
+	Outline:    colornames.Black,
+	Edge:       colornames.Red,
+	Vertex:     colornames.Blue,
+	Label:      colornames.Black,
+	Route:      colornames.Lime,
+
+	GridThickness:    0.1,
+	OutlineThickness: 0.1,
+	EdgeThickness:    0.05,
+	VertexRadius:     10e3, // 10km
+	LabelSize:        0.32,
+}
+
+// Map holds the layers to draw and how to draw them. Layers are drawn in the
+// same order as the viewer: grid, outlines, edges, vertices, labels, then
+// route.
+type Map struct {
+	Width, Height int // pixels
+	Bounds        Bounds
+	Style         Style
+
+	Grid     bool         // draw lines every 10 degrees
+	Outlines []maps.State // such as from maps.StateFromJSON
+	Graph    hwy.Graph
+	Labels   bool        // label vertices with Place.Name()
+	Route    []hwy.Place // such as from PathMap.Path
+}
+
+// NewMap creates a Map of width by height pixels showing g within bounds,
+// using DefaultStyle.
+func NewMap(width, height int, bounds Bounds, g hwy.Graph) *Map {
+	return &Map{
+		Width:  width,
+		Height: height,
+		Bounds: bounds,
+		Style:  DefaultStyle,
+		Graph:  g,
+	}
+}
+
+// scale gives the number of pixels per degree, chosen so that Bounds fits in
+// the image.
+func (m *Map) scale() float64 {
+	return math.Min(
+		float64(m.Width)/(m.Bounds.MaxLon-m.Bounds.MinLon),
+		float64(m.Height)/(m.Bounds.MaxLat-m.Bounds.MinLat))
+}
+
+// project converts a longitude and latitude to pixel coordinates, with the
+// center of Bounds in the center of the image and y increasing downwards.
+func (m *Map) project(lon, lat float64) (x, y float64) {
+	s := m.scale()
+	clon := (m.Bounds.MinLon + m.Bounds.MaxLon) / 2
+	clat := (m.Bounds.MinLat + m.Bounds.MaxLat) / 2
+	x = float64(m.Width)/2 + (lon-clon)*s
+	y = float64(m.Height)/2 - (lat-clat)*s
+	return
+}
+
+// vertexRadius gives the radius in pixels of a vertex at the given latitude.
+func (m *Map) vertexRadius(latitude float64) float64 {
+	return m.Style.VertexRadius * degreesPerMeter(latitude) * m.scale()
+}
+
+// degreesPerMeter gives the number of degrees of longitude in a meter at the
+// given latitude. Same as the viewer's mTd().
+func degreesPerMeter(latitude float64) float64 {
+	const mPDegEquator = 111319.9
+	return 1 / (mPDegEquator * math.Cos(latitude*math.Pi/180))
+}
+
+// outlineStep is how many points of each outline polygon to skip, the same
+// as the viewer.
+const outlineStep = 10
+
+// outlinePoints gives the longitude and latitude of every outlineStep'th
+// point of poly.
+func outlinePoints(poly []maps.Point) [][2]float64 {
+	pts := make([][2]float64, 0, len(poly)/outlineStep+1)
+	for j := 0; j < len(poly); j += outlineStep {
+		// maps.Point is latitude, longitude
+		pts = append(pts, [2]float64{poly[j][1], poly[j][0]})
+	}
+	return pts
+}
+
+// gridLines gives the end points (lon, lat) of the grid lines.
+func gridLines() [][2][2]float64 {
+	const xmax = 180
+	const ymax = 90
+	lines := [][2][2]float64{}
+	for x := -xmax; x <= xmax; x += 10 {
+		lines = append(lines, [2][2]float64{{float64(x), ymax}, {float64(x), -ymax}})
+	}
+	for y := -ymax; y <= ymax; y += 10 {
+		lines = append(lines, [2][2]float64{{xmax, float64(y)}, {-xmax, float64(y)}})
+	}
+	return lines
+}
+
+// places gives the places of the graph sorted by state, so that the output
+// is the same every time.
+func (m *Map) places() []hwy.Place {
+	places := m.Graph.Places()
+	sort.Sort(hwy.ByState(places))
+	return places
+}
+
+// edges gives each connection in the graph once, as pairs of places.
+func (m *Map) edges() [][2]hwy.Place {
+	edges := [][2]hwy.Place{}
+	for _, orig := range m.places() {
+		dests := make([]hwy.Place, 0, len(m.Graph[orig]))
+		for dest := range m.Graph[orig] {
+			dests = append(dests, dest)
+		}
+		sort.Sort(hwy.ByState(dests))
+
+		for _, dest := range dests {
+			// skip the second direction of two way connections
+			if _, ok := m.Graph.Edge(dest, orig); ok && hwy.ByState([]hwy.Place{dest, orig}).Less(0, 1) {
+				continue
+			}
+			edges = append(edges, [2]hwy.Place{orig, dest})
+		}
+	}
+	return edges
+}
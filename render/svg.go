@@ -0,0 +1,120 @@
+package render
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+)
+
+// hexColor gives the SVG (CSS) form of c, ignoring alpha.
+func hexColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// svgWriter writes SVG elements, remembering the first error.
+type svgWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (sw *svgWriter) printf(format string, a ...interface{}) {
+	if sw.err == nil {
+		_, sw.err = fmt.Fprintf(sw.w, format, a...)
+	}
+}
+
+// escape replaces the characters that are special in XML text.
+var escape = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace
+
+// WriteSVG draws the map to w as an SVG document.
+func (m *Map) WriteSVG(w io.Writer) error {
+	s := m.scale()
+	st := m.Style
+	sw := &svgWriter{w: w}
+
+	sw.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %[1]d %[2]d">`+"\n",
+		m.Width, m.Height)
+	sw.printf(`<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hexColor(st.Background))
+
+	if m.Grid {
+		sw.printf(`<g id="grid" stroke="%s" stroke-width="%.2f">`+"\n", hexColor(st.Grid), st.GridThickness*s)
+		for _, l := range gridLines() {
+			x1, y1 := m.project(l[0][0], l[0][1])
+			x2, y2 := m.project(l[1][0], l[1][1])
+			sw.printf(`<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f"/>`+"\n", x1, y1, x2, y2)
+		}
+		sw.printf("</g>\n")
+	}
+
+	if len(m.Outlines) > 0 {
+		sw.printf(`<g id="outlines" fill="none" stroke="%s" stroke-width="%.2f">`+"\n",
+			hexColor(st.Outline), st.OutlineThickness*s)
+		for _, state := range m.Outlines {
+			for _, poly := range state.Polygons {
+				sw.printf(`<polygon points="%s"/>`+"\n", m.svgPoints(outlinePoints(poly)))
+			}
+		}
+		sw.printf("</g>\n")
+	}
+
+	if m.Graph != nil {
+		sw.printf(`<g id="edges" stroke="%s" stroke-width="%.2f">`+"\n", hexColor(st.Edge), st.EdgeThickness*s)
+		for _, e := range m.edges() {
+			x1, y1 := m.project(e[0].Longitude, e[0].Latitude)
+			x2, y2 := m.project(e[1].Longitude, e[1].Latitude)
+			sw.printf(`<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f"/>`+"\n", x1, y1, x2, y2)
+		}
+		sw.printf("</g>\n")
+
+		sw.printf(`<g id="vertices" fill="%s">`+"\n", hexColor(st.Vertex))
+		for _, p := range m.places() {
+			x, y := m.project(p.Longitude, p.Latitude)
+			sw.printf(`<circle cx="%.2f" cy="%.2f" r="%.2f"/>`+"\n", x, y, m.vertexRadius(p.Latitude))
+		}
+		sw.printf("</g>\n")
+
+		if m.Labels {
+			sw.printf(`<g id="labels" fill="%s" font-family="sans-serif" font-size="%.2f">`+"\n",
+				hexColor(st.Label), st.LabelSize*s)
+			for _, p := range m.places() {
+				x, y := m.project(p.Longitude, p.Latitude)
+				r := m.vertexRadius(p.Latitude)
+				sw.printf(`<text x="%.2f" y="%.2f">%s</text>`+"\n", x+r, y-r, escape(p.Name()))
+			}
+			sw.printf("</g>\n")
+		}
+	}
+
+	if len(m.Route) > 0 {
+		sw.printf(`<g id="route" fill="%s" stroke="%[1]s">`+"\n", hexColor(st.Route))
+		pts := make([][2]float64, len(m.Route))
+		for i, p := range m.Route {
+			pts[i] = [2]float64{p.Longitude, p.Latitude}
+		}
+		sw.printf(`<polyline fill="none" stroke-width="%.2f" points="%s"/>`+"\n", st.EdgeThickness*s, m.svgPoints(pts))
+		for _, p := range m.Route {
+			x, y := m.project(p.Longitude, p.Latitude)
+			sw.printf(`<circle stroke="none" cx="%.2f" cy="%.2f" r="%.2f"/>`+"\n", x, y, m.vertexRadius(p.Latitude))
+		}
+		sw.printf("</g>\n")
+	}
+
+	sw.printf("</svg>\n")
+	return sw.err
+}
+
+// svgPoints gives the "points" attribute for a polygon or polyline through
+// pts (lon, lat).
+func (m *Map) svgPoints(pts [][2]float64) string {
+	b := strings.Builder{}
+	for i, pt := range pts {
+		x, y := m.project(pt[0], pt[1])
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%.2f,%.2f", x, y)
+	}
+	return b.String()
+}
@@ -0,0 +1,130 @@
+package hwy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Route is a path through the graph along with its total cost, distance, and
+// travel time.
+type Route struct {
+	Places     []Place
+	Cost       float64 // total of the Accessor used to find the route
+	Distance   float64 // meters
+	TravelTime time.Duration
+}
+
+// Miles gives the total distance of the route in miles.
+func (r Route) Miles() float64 {
+	return r.Distance * MetersToMiles
+}
+
+// route totals up the edges along path to create a Route. The edges must
+// exist in g.
+func (g Graph) route(path []Place, by Accessor) Route {
+	r := Route{Places: path}
+	for i := 0; i < len(path)-1; i++ {
+		w := g[path[i]][path[i+1]]
+		r.Cost += by(w)
+		r.Distance += w.Distance
+		r.TravelTime += w.TravelTime
+	}
+	return r
+}
+
+// Step is a place along an Itinerary, with the total distance and time
+// traveled from the start of the itinerary to reach it.
+type Step struct {
+	Place   Place
+	Miles   float64
+	Elapsed time.Duration
+}
+
+// Leg is the part of an Itinerary between two consecutive stops.
+type Leg struct {
+	Route
+
+	// Steps has one Step for each place in Route.Places, including the stops
+	// at both ends.
+	Steps []Step
+}
+
+// Itinerary is a trip through a series of stops, broken into legs.
+type Itinerary struct {
+	Legs []Leg
+}
+
+// Miles gives the total distance of the itinerary in miles.
+func (it Itinerary) Miles() float64 {
+	if len(it.Legs) == 0 {
+		return 0
+	}
+	steps := it.Legs[len(it.Legs)-1].Steps
+	return steps[len(steps)-1].Miles
+}
+
+// Duration gives the total travel time of the itinerary.
+func (it Itinerary) Duration() time.Duration {
+	if len(it.Legs) == 0 {
+		return 0
+	}
+	steps := it.Legs[len(it.Legs)-1].Steps
+	return steps[len(steps)-1].Elapsed
+}
+
+// PrettyPrint writes a nicely formatted list of the legs of the itinerary and
+// each place passed through to w, with the distance and time traveled so far.
+func (it Itinerary) PrettyPrint(w io.Writer) {
+	for i, leg := range it.Legs {
+		orig, dest := leg.Places[0], leg.Places[len(leg.Places)-1]
+		fmt.Fprintf(w, "leg %d: %s, %s to %s, %s (%.1fmi, %s)\n",
+			i+1, orig.City, orig.State, dest.City, dest.State, leg.Miles(), leg.TravelTime)
+		for _, s := range leg.Steps {
+			fmt.Fprintf(w, "\t%-16s%3s%8.1fmi%12s\n", s.Place.City, s.Place.State, s.Miles, s.Elapsed)
+		}
+	}
+	fmt.Fprintf(w, "total: %.1fmi, %s\n", it.Miles(), it.Duration())
+}
+
+// RouteVia finds the shortest route (according to 'by') that goes through
+// each of stops in order. An error is returned if there are fewer than 2
+// stops, a stop is not in the graph, the same stop is given twice in a row, or
+// there is no path between two consecutive stops (ErrNoPath).
+func (g Graph) RouteVia(by Accessor, stops ...Place) (Itinerary, error) {
+	if len(stops) < 2 {
+		return Itinerary{}, errors.New("a route needs at least 2 stops")
+	}
+	for _, s := range stops {
+		if _, ok := g[s]; !ok {
+			return Itinerary{}, fmt.Errorf("%s is not in the graph", s.Name())
+		}
+	}
+
+	it := Itinerary{Legs: make([]Leg, 0, len(stops)-1)}
+	var miles float64
+	var elapsed time.Duration
+	for i := 0; i < len(stops)-1; i++ {
+		orig, dest := stops[i], stops[i+1]
+		if orig == dest {
+			return Itinerary{}, fmt.Errorf("%s is repeated", orig.Name())
+		}
+		path, _ := g.ShortestPathTo(orig, dest, by).Path(dest)
+		if path == nil {
+			return Itinerary{}, fmt.Errorf("%s to %s: %w", orig.Name(), dest.Name(), ErrNoPath)
+		}
+
+		leg := Leg{Route: g.route(path, by), Steps: make([]Step, len(path))}
+		leg.Steps[0] = Step{Place: orig, Miles: miles, Elapsed: elapsed}
+		for j := 1; j < len(path); j++ {
+			w := g[path[j-1]][path[j]]
+			miles += w.Distance * MetersToMiles
+			elapsed += w.TravelTime
+			leg.Steps[j] = Step{Place: path[j], Miles: miles, Elapsed: elapsed}
+		}
+		it.Legs = append(it.Legs, leg)
+	}
+
+	return it, nil
+}
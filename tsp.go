@@ -0,0 +1,281 @@
+package hwy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrNoPath is returned when two places that must be connected are not.
+var ErrNoPath = errors.New("no path between places")
+
+// TourMode selects how PlanTour solves the traveling salesman problem.
+type TourMode int
+
+// TourModes for PlanTour.
+const (
+	// TourAuto uses TourExact for up to MaxExactStops stops, and TourApprox
+	// for more.
+	TourAuto TourMode = iota
+
+	// TourApprox builds a tour by visiting the nearest unvisited stop next,
+	// then improves it with 2-opt and Or-opt moves. The result is usually
+	// close to optimal.
+	TourApprox
+
+	// TourExact finds the optimal tour using the Held-Karp algorithm. It takes
+	// time and memory exponential in the number of stops.
+	TourExact
+)
+
+// MaxExactStops is the most stops for which PlanTour will find an exact tour.
+const MaxExactStops = 15
+
+// Tour is a round trip that visits each of a set of stops once, then returns
+// to the first stop.
+type Tour struct {
+	// Stops in the order they are visited, starting with the first stop given
+	// to PlanTour. The return to the first stop is not repeated at the end.
+	Stops []Place
+
+	// Legs are the highway routes between stops. Legs[i] is the route from
+	// Stops[i] to the next stop, and the last leg returns to Stops[0].
+	Legs []Route
+
+	Cost       float64 // total of the Accessor used to plan the tour
+	Distance   float64 // meters
+	TravelTime time.Duration
+}
+
+// Miles gives the total distance of the tour in miles.
+func (t Tour) Miles() float64 {
+	return t.Distance * MetersToMiles
+}
+
+// PlanTour finds a short round trip visiting each of stops, starting and
+// ending at stops[0], with costs according to 'by'. The tour is planned on the
+// "metric closure" of the stops: the cost between each pair of stops is that
+// of the shortest path between them in the graph, so the tour may pass
+// through other places (or other stops) along the way.
+//
+// An error is returned if there are fewer than 2 stops, a stop is repeated or
+// not in the graph, a stop can't be reached from the others (ErrNoPath), or
+// TourExact is requested for more than MaxExactStops stops.
+//
+// https://en.wikipedia.org/wiki/Travelling_salesman_problem
+func (g Graph) PlanTour(stops []Place, by Accessor, mode TourMode) (Tour, error) {
+	if len(stops) < 2 {
+		return Tour{}, errors.New("a tour needs at least 2 stops")
+	}
+	seen := make(map[Place]bool, len(stops))
+	for _, s := range stops {
+		if _, ok := g[s]; !ok {
+			return Tour{}, fmt.Errorf("%s is not in the graph", s.Name())
+		}
+		if seen[s] {
+			return Tour{}, fmt.Errorf("%s is repeated", s.Name())
+		}
+		seen[s] = true
+	}
+	if mode == TourAuto {
+		mode = TourApprox
+		if len(stops) <= MaxExactStops {
+			mode = TourExact
+		}
+	}
+	if mode == TourExact && len(stops) > MaxExactStops {
+		return Tour{}, fmt.Errorf("exact tour limited to %d stops", MaxExactStops)
+	}
+
+	// build the metric closure
+	n := len(stops)
+	cost := make([][]float64, n)
+	paths := make([][][]Place, n)
+	for i, orig := range stops {
+		pm := g.ShortestPath(orig, by)
+		cost[i] = make([]float64, n)
+		paths[i] = make([][]Place, n)
+		for j, dest := range stops {
+			if i == j {
+				continue
+			}
+			path, sum := pm.Path(dest)
+			if path == nil {
+				return Tour{}, fmt.Errorf("%s to %s: %w", orig.Name(), dest.Name(), ErrNoPath)
+			}
+			cost[i][j] = sum
+			paths[i][j] = path
+		}
+	}
+
+	var order []int
+	switch mode {
+	case TourExact:
+		order = heldKarp(cost)
+	default:
+		order = improveTour(nearestNeighborTour(cost), cost)
+	}
+
+	// expand the tour into the full route
+	t := Tour{Stops: make([]Place, n), Legs: make([]Route, n)}
+	for i, s := range order {
+		next := order[(i+1)%n]
+		t.Stops[i] = stops[s]
+		t.Legs[i] = g.route(paths[s][next], by)
+		t.Cost += t.Legs[i].Cost
+		t.Distance += t.Legs[i].Distance
+		t.TravelTime += t.Legs[i].TravelTime
+	}
+
+	return t, nil
+}
+
+// tourCost gives the cost of the round trip visiting stops in order.
+func tourCost(order []int, cost [][]float64) (sum float64) {
+	for i := range order {
+		sum += cost[order[i]][order[(i+1)%len(order)]]
+	}
+	return
+}
+
+// nearestNeighborTour builds a tour starting at stop 0 by always going to
+// the closest stop not yet visited.
+func nearestNeighborTour(cost [][]float64) []int {
+	n := len(cost)
+	visited := make([]bool, n)
+	order := make([]int, 1, n)
+	visited[0] = true
+
+	for len(order) < n {
+		last := order[len(order)-1]
+		best, bestCost := -1, math.Inf(1)
+		for j := 0; j < n; j++ {
+			if !visited[j] && cost[last][j] < bestCost {
+				best, bestCost = j, cost[last][j]
+			}
+		}
+		visited[best] = true
+		order = append(order, best)
+	}
+
+	return order
+}
+
+// improveTour repeatedly applies 2-opt moves (reversing a section of the
+// tour) and Or-opt moves (moving a section of 1 to 3 stops elsewhere in the
+// tour) until neither makes the tour cheaper. The first stop stays first.
+// Because the costs may differ in each direction, each move is checked by
+// recalculating the cost of the whole tour.
+func improveTour(order []int, cost [][]float64) []int {
+	n := len(order)
+	best := tourCost(order, cost)
+	candidate := make([]int, n)
+
+	improved := true
+	for improved {
+		improved = false
+
+		// 2-opt
+		for i := 1; i < n-1; i++ {
+			for j := i + 1; j < n; j++ {
+				copy(candidate, order)
+				for a, b := i, j; a < b; a, b = a+1, b-1 {
+					candidate[a], candidate[b] = candidate[b], candidate[a]
+				}
+				if c := tourCost(candidate, cost); c < best {
+					best = c
+					copy(order, candidate)
+					improved = true
+				}
+			}
+		}
+
+		// Or-opt
+		for length := 1; length <= 3; length++ {
+			for i := 1; i+length <= n; i++ {
+				segment := append([]int{}, order[i:i+length]...)
+				rest := append(append([]int{}, order[:i]...), order[i+length:]...)
+				for k := 1; k <= len(rest); k++ {
+					if k == i {
+						continue // same place as it was
+					}
+					candidate = candidate[:0]
+					candidate = append(candidate, rest[:k]...)
+					candidate = append(candidate, segment...)
+					candidate = append(candidate, rest[k:]...)
+					if c := tourCost(candidate, cost); c < best {
+						best = c
+						copy(order, candidate)
+						improved = true
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return order
+}
+
+// heldKarp finds the optimal tour starting at stop 0 using dynamic
+// programming over subsets of the other stops.
+//
+// https://en.wikipedia.org/wiki/Held%E2%80%93Karp_algorithm
+func heldKarp(cost [][]float64) []int {
+	n := len(cost)
+	m := n - 1 // stops other than 0; stop j+1 is bit j in a set
+	full := 1<<uint(m) - 1
+
+	// best[set][j] is the cost of the cheapest path starting at stop 0,
+	// visiting every stop in set, and ending at stop j+1 (which is in set).
+	best := make([][]float64, full+1)
+	parent := make([][]int, full+1)
+	for set := range best {
+		best[set] = make([]float64, m)
+		parent[set] = make([]int, m)
+		for j := range best[set] {
+			best[set][j] = math.Inf(1)
+			parent[set][j] = -1
+		}
+	}
+	for j := 0; j < m; j++ {
+		best[1<<uint(j)][j] = cost[0][j+1]
+	}
+
+	for set := 1; set <= full; set++ {
+		for j := 0; j < m; j++ {
+			if set&(1<<uint(j)) == 0 || math.IsInf(best[set][j], 1) {
+				continue
+			}
+			for k := 0; k < m; k++ {
+				if set&(1<<uint(k)) != 0 {
+					continue
+				}
+				next := set | 1<<uint(k)
+				if c := best[set][j] + cost[j+1][k+1]; c < best[next][k] {
+					best[next][k] = c
+					parent[next][k] = j
+				}
+			}
+		}
+	}
+
+	// close the loop back to stop 0, then follow the parents backwards
+	last, lastCost := 0, math.Inf(1)
+	for j := 0; j < m; j++ {
+		if c := best[full][j] + cost[j+1][0]; c < lastCost {
+			last, lastCost = j, c
+		}
+	}
+
+	order := make([]int, n)
+	set := full
+	for i := n - 1; i > 0; i-- {
+		order[i] = last + 1
+		prev := parent[set][last]
+		set &^= 1 << uint(last)
+		last = prev
+	}
+	return order
+}
@@ -0,0 +1,207 @@
+package hwy
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// These types and functions look for edges with suspicious Weights, which
+// usually come from a place being geocoded to the wrong location or a route
+// that took a strange detour. Each edge's road distance is compared to the
+// great-circle distance between its places, and its travel time to its
+// distance.
+
+// EdgeCheck holds the measures of one edge used to find bad data.
+type EdgeCheck struct {
+	From, To Place
+	Weight   Weight
+
+	// Circuity is the ratio of the road distance to the great-circle
+	// distance. It can't be less than 1 for real roads.
+	Circuity float64
+
+	// Speed is the implied average speed in miles per hour.
+	Speed float64
+
+	// Asymmetry is the larger of the relative differences in distance and
+	// travel time between this edge and the one in the other direction. It is
+	// 0 if there is no edge in the other direction.
+	Asymmetry float64
+
+	// Problems describes each way the edge is suspicious. It's empty if the
+	// edge looks fine.
+	Problems []string
+}
+
+// ValidationOptions are the limits outside of which an edge is flagged by
+// Validate.
+type ValidationOptions struct {
+	MinCircuity, MaxCircuity float64
+	MinSpeed, MaxSpeed       float64 // mph
+	MaxAsymmetry             float64 // fraction, eg 0.15 for 15%
+}
+
+// DefaultValidation has limits that flag a few edges in the US highway data.
+// MinCircuity is a little less than 1 to allow for rounding.
+var DefaultValidation = ValidationOptions{
+	MinCircuity:  0.99,
+	MaxCircuity:  1.6,
+	MinSpeed:     30,
+	MaxSpeed:     DefaultMaxSpeed,
+	MaxAsymmetry: 0.15,
+}
+
+// ValidationReport holds the checks of every edge in a graph.
+type ValidationReport struct {
+	// Edges are sorted by From then To.
+	Edges []EdgeCheck
+
+	MedianCircuity float64
+	MedianSpeed    float64
+
+	Options ValidationOptions
+}
+
+// Flagged gives the edges that have problems.
+func (r ValidationReport) Flagged() []EdgeCheck {
+	flagged := []EdgeCheck{}
+	for _, e := range r.Edges {
+		if len(e.Problems) > 0 {
+			flagged = append(flagged, e)
+		}
+	}
+	return flagged
+}
+
+// PrettyPrint writes a table of the flagged edges to w, or of all edges if
+// all is true, followed by a summary.
+func (r ValidationReport) PrettyPrint(w io.Writer, all bool) {
+	edges := r.Edges
+	if !all {
+		edges = r.Flagged()
+	}
+
+	fmt.Fprintf(w, "%-22s%-22s%9s%10s%9s%7s%6s  %s\n",
+		"from", "to", "miles", "time", "circuity", "mph", "asym", "problems")
+	for _, e := range edges {
+		line := fmt.Sprintf("%-22s%-22s%9.1f%10s%9.2f%7.1f%5.0f%%  %s",
+			e.From.Name(), e.To.Name(), e.Weight.Distance*MetersToMiles, e.Weight.TravelTime,
+			e.Circuity, e.Speed, e.Asymmetry*100, strings.Join(e.Problems, "; "))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+	fmt.Fprintf(w, "%d of %d edges flagged. median circuity %.2f, median speed %.1f mph.\n",
+		len(r.Flagged()), len(r.Edges), r.MedianCircuity, r.MedianSpeed)
+}
+
+// Validate checks the Weight of every edge in the graph against opts. An edge
+// is flagged if its circuity or implied speed is outside the limits, if its
+// Weight is zero (it was never found), if it has no edge in the other
+// direction, or if its distance or travel time differ from those of the
+// other direction by more than opts.MaxAsymmetry.
+func (g Graph) Validate(opts ValidationOptions) ValidationReport {
+	report := ValidationReport{Options: opts}
+
+	for from, dests := range g {
+		for to, w := range dests {
+			report.Edges = append(report.Edges, g.checkEdge(from, to, w, opts))
+		}
+	}
+
+	sort.Slice(report.Edges, func(i, j int) bool {
+		a, b := report.Edges[i], report.Edges[j]
+		if a.From != b.From {
+			return lessByState(a.From, b.From)
+		}
+		return lessByState(a.To, b.To)
+	})
+
+	circuities := []float64{}
+	speeds := []float64{}
+	for _, e := range report.Edges {
+		if e.Weight != (Weight{}) {
+			circuities = append(circuities, e.Circuity)
+			speeds = append(speeds, e.Speed)
+		}
+	}
+	report.MedianCircuity = median(circuities)
+	report.MedianSpeed = median(speeds)
+
+	return report
+}
+
+// checkEdge measures the edge from 'from' to 'to' with Weight w.
+func (g Graph) checkEdge(from, to Place, w Weight, opts ValidationOptions) EdgeCheck {
+	e := EdgeCheck{From: from, To: to, Weight: w}
+	flag := func(format string, a ...interface{}) {
+		e.Problems = append(e.Problems, fmt.Sprintf(format, a...))
+	}
+
+	if w == (Weight{}) {
+		flag("no weight")
+		return e
+	}
+
+	straight := sphericalLawOfCos(from.Latitude, from.Longitude, to.Latitude, to.Longitude)
+	if straight > 0 {
+		e.Circuity = w.Distance / straight
+	} else {
+		e.Circuity = math.Inf(1) // same location
+	}
+	if w.TravelTime > 0 {
+		e.Speed = w.Distance * MetersToMiles / w.TravelTime.Hours()
+	}
+
+	switch {
+	case e.Circuity < opts.MinCircuity:
+		flag("circuity below %.2f", opts.MinCircuity)
+	case e.Circuity > opts.MaxCircuity:
+		flag("circuity above %.2f", opts.MaxCircuity)
+	}
+	switch {
+	case e.Speed < opts.MinSpeed:
+		flag("speed below %.0f mph", opts.MinSpeed)
+	case e.Speed > opts.MaxSpeed:
+		flag("speed above %.0f mph", opts.MaxSpeed)
+	}
+
+	reverse, ok := g.Edge(to, from)
+	if !ok {
+		flag("one way")
+		return e
+	}
+	if reverse != (Weight{}) {
+		e.Asymmetry = math.Max(
+			relativeDiff(w.Distance, reverse.Distance),
+			relativeDiff(float64(w.TravelTime), float64(reverse.TravelTime)))
+		if e.Asymmetry > opts.MaxAsymmetry {
+			flag("differs from reverse by %.0f%%", e.Asymmetry*100)
+		}
+	}
+
+	return e
+}
+
+// relativeDiff gives the difference between a and b relative to the larger.
+func relativeDiff(a, b float64) float64 {
+	if a == b {
+		return 0
+	}
+	return math.Abs(a-b) / math.Max(math.Abs(a), math.Abs(b))
+}
+
+// median gives the middle value of xs, which is sorted in place. Gives 0 if
+// xs is empty.
+func median(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sort.Float64s(xs)
+	mid := len(xs) / 2
+	if len(xs)%2 == 0 {
+		return (xs[mid-1] + xs[mid]) / 2
+	}
+	return xs[mid]
+}